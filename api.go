@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Values of the transport input
+const (
+	webhookTransport = "webhook"
+	apiTransport     = "api"
+)
+
+// Values of the api_action input
+const (
+	createAction = "create"
+	updateAction = "update"
+	deleteAction = "delete"
+)
+
+const (
+	defaultTokenURL   = "https://oauth2.googleapis.com/token"
+	defaultAPIBaseURL = "https://chat.googleapis.com"
+	chatBotScope      = "https://www.googleapis.com/auth/chat.bot"
+	jwtBearerGrant    = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+// ServiceAccountKey is the JSON key of a Google Cloud service account
+type ServiceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// parseServiceAccountKey parses a service account key, which is either the JSON itself or the path to a JSON file
+func parseServiceAccountKey(s string) (key ServiceAccountKey, err error) {
+	raw := []byte(strings.TrimSpace(s))
+	if !bytes.HasPrefix(raw, []byte("{")) {
+		raw, err = ioutil.ReadFile(string(raw))
+		if err != nil {
+			err = fmt.Errorf("failed to read the service account key: %s", err)
+			return
+		}
+	}
+
+	if err = json.Unmarshal(raw, &key); err != nil {
+		err = fmt.Errorf("failed to parse the service account key: %s", err)
+		return
+	}
+
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		err = fmt.Errorf("the service account key should contain a client_email and a private_key")
+	}
+
+	return
+}
+
+// signer returns the RSA private key of the service account
+func (k ServiceAccountKey) signer() (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("the private_key of the service account is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the private_key of the service account: %s", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private_key of the service account is not an RSA key")
+	}
+
+	return key, nil
+}
+
+// signJWT creates a JWT assertion, signed with the service account key, to request an access token
+func signJWT(key ServiceAccountKey, audience string, issuedAt time.Time) (string, error) {
+	privateKey, err := key.signer()
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"kid": key.PrivateKeyID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   key.ClientEmail,
+		"scope": chatBotScope,
+		"aud":   audience,
+		"iat":   issuedAt.Unix(),
+		"exp":   issuedAt.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the token request: %s", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// APIClient calls the Google Chat REST API, authenticated as a service account
+// More info at https://developers.google.com/hangouts/chat/reference/rest
+type APIClient struct {
+	Key        ServiceAccountKey
+	TokenURL   string
+	BaseURL    string
+	HTTPClient *http.Client
+	Retry      RetryPolicy
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewAPIClient creates an APIClient from the step configuration
+func NewAPIClient(c Config) (*APIClient, error) {
+	key, err := parseServiceAccountKey(string(c.ServiceAccountKey))
+	if err != nil {
+		return nil, err
+	}
+
+	tokenURL := c.TokenURL
+	if tokenURL == "" {
+		tokenURL = key.TokenURI
+	}
+	if tokenURL == "" {
+		tokenURL = defaultTokenURL
+	}
+
+	baseURL := c.APIBaseURL
+	if baseURL == "" {
+		baseURL = defaultAPIBaseURL
+	}
+
+	return &APIClient{
+		Key:        key,
+		TokenURL:   tokenURL,
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{},
+		Retry:      newRetryPolicy(c),
+	}, nil
+}
+
+// token returns a cached access token, or exchanges a new JWT assertion for one
+func (c *APIClient) token() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" && now().Before(c.expiresAt) {
+		return c.accessToken, nil
+	}
+
+	assertion, err := signJWT(c.Key, c.TokenURL, now())
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {jwtBearerGrant},
+		"assertion":  {assertion},
+	}
+
+	req, err := http.NewRequest("POST", c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create the token request: %s", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	body, _, err := googlechat.DoRequest(c.HTTPClient, req)
+	if err != nil {
+		// Keep the DeliveryError, so failures of the token endpoint are retried like the other requests
+		if deliveryErr, ok := err.(*googlechat.DeliveryError); ok {
+			return "", deliveryErr.WithPrefix("failed to get an access token")
+		}
+		return "", fmt.Errorf("failed to get an access token: %s", err)
+	}
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.AccessToken == "" {
+		return "", fmt.Errorf("failed to get an access token: invalid response: %s", body)
+	}
+
+	c.accessToken = resp.AccessToken
+	// Renew the token a minute before it expires
+	c.expiresAt = now().Add(time.Duration(resp.ExpiresIn)*time.Second - time.Minute)
+
+	return c.accessToken, nil
+}
+
+// call makes an authenticated request to the api, retrying temporary failures. The warnings are the problems
+// with the response of a request which succeeded, see googlechat.DoRequest.
+func (c *APIClient) call(method, path string, query url.Values, in interface{}) (body []byte, warnings []string, err error) {
+	var b []byte
+	if in != nil {
+		b, err = json.Marshal(in)
+		if err != nil {
+			return
+		}
+		log.Debugf("Request to Google Chat: %s %s: %s\n", method, path, b)
+	}
+
+	u := c.BaseURL + "/v1/" + strings.TrimPrefix(path, "/")
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	err = withRetry(path, c.Retry, func() error {
+		token, err := c.token()
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequest(method, u, bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("failed to create the request: %s", err)
+		}
+		req.Header.Add("Authorization", "Bearer "+token)
+		if b != nil {
+			req.Header.Add("Content-Type", "application/json; charset=utf-8")
+		}
+
+		body, warnings, err = googlechat.DoRequest(c.HTTPClient, req)
+		return err
+	})
+	if err == nil && body != nil {
+		log.Debugf("Response from Google Chat: %s\n", body)
+	}
+
+	return
+}
+
+// CreateMessage creates a message in a space, e.g. spaces/AAA
+func (c *APIClient) CreateMessage(space string, msg googlechat.Message, replyOption string) (*googlechat.MessageResponse, error) {
+	query := url.Values{}
+	if msg.Thread != nil && msg.Thread.ThreadKey != "" {
+		query.Set("threadKey", msg.Thread.ThreadKey)
+		query.Set("messageReplyOption", replyOptionValue(replyOption))
+	}
+
+	body, warnings, err := c.call("POST", strings.TrimSuffix(space, "/")+"/messages", query, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := googlechat.ParseMessageResponse(body)
+	resp.Warnings = append(warnings, resp.Warnings...)
+	return resp, nil
+}
+
+// UpdateMessage replaces the text and cards of a message, e.g. spaces/AAA/messages/BBB
+func (c *APIClient) UpdateMessage(name string, msg googlechat.Message) (*googlechat.MessageResponse, error) {
+	updateMask := "text,cards"
+	if len(msg.CardsV2) > 0 {
+		updateMask = "text,cardsV2"
+	}
+
+	body, warnings, err := c.call("PATCH", name, url.Values{"updateMask": {updateMask}}, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := googlechat.ParseMessageResponse(body)
+	resp.Warnings = append(warnings, resp.Warnings...)
+	return resp, nil
+}
+
+// DeleteMessage deletes a message, e.g. spaces/AAA/messages/BBB
+func (c *APIClient) DeleteMessage(name string) error {
+	_, warnings, err := c.call("DELETE", name, nil, nil)
+	warnResponse(name, warnings)
+	return err
+}
+
+// callAPI performs the configured api action with the message
+func callAPI(conf Config, msg googlechat.Message) (*googlechat.MessageResponse, error) {
+	client, err := NewAPIClient(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *googlechat.MessageResponse
+	switch conf.APIAction {
+	case updateAction:
+		resp, err = client.UpdateMessage(conf.MessageName, msg)
+	case deleteAction:
+		return nil, client.DeleteMessage(conf.MessageName)
+	default:
+		resp, err = client.CreateMessage(conf.Space, msg, conf.ThreadReplyOption)
+	}
+	if resp != nil {
+		warnResponse(conf.APIAction, resp.Warnings)
+	}
+	return resp, err
+}
+
+// validateAPIConfig checks the inputs required by the api transport
+func validateAPIConfig(conf *Config) error {
+	if conf.ServiceAccountKey == "" {
+		return fmt.Errorf("ServiceAccountKey is empty. You need to provide one when using the api transport")
+	}
+
+	switch conf.APIAction {
+	case updateAction, deleteAction:
+		if conf.MessageName == "" {
+			return fmt.Errorf("MessageName is empty. You need to provide one to %s a message", conf.APIAction)
+		}
+	default:
+		if conf.Space == "" {
+			return fmt.Errorf("Space is empty. You need to provide one to create a message")
+		}
+	}
+
+	return nil
+}
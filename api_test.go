@@ -0,0 +1,296 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/bitrise-io/go-steputils/stepconf"
+	"github.com/google/go-cmp/cmp"
+)
+
+// stubAPI stands in for both the token endpoint and the Chat API
+type stubAPI struct {
+	t         *testing.T
+	publicKey *rsa.PublicKey
+	server    *httptest.Server
+	requests  []string
+	tokens    int
+	// tokenErrors is the number of token requests answered with 503 Service Unavailable
+	tokenErrors int
+}
+
+func newStubAPI(t *testing.T, publicKey *rsa.PublicKey) *stubAPI {
+	stub := &stubAPI{t: t, publicKey: publicKey}
+	stub.server = httptest.NewServer(http.HandlerFunc(stub.handle))
+	return stub
+}
+
+func (s *stubAPI) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		s.tokens++
+
+		if err := r.ParseForm(); err != nil {
+			s.t.Errorf("Failed to parse token request: %s", err)
+		}
+		if r.Form.Get("grant_type") != jwtBearerGrant {
+			s.t.Errorf("Unexpected grant type: %s", r.Form.Get("grant_type"))
+		}
+		s.verifyJWT(r.Form.Get("assertion"))
+
+		if s.tokenErrors > 0 {
+			s.tokenErrors--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`{"access_token":"token","expires_in":3600,"token_type":"Bearer"}`))
+		return
+	}
+
+	if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+
+	switch r.Method {
+	case "DELETE":
+		w.Write([]byte(`{}`))
+	default:
+		w.Write([]byte(`{"name":"spaces/AAA/messages/BBB","thread":{"name":"spaces/AAA/threads/CCC"},"space":{"name":"spaces/AAA"}}`))
+	}
+}
+
+func (s *stubAPI) verifyJWT(assertion string) {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		s.t.Errorf("Invalid JWT: %s", assertion)
+		return
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		s.t.Errorf("Invalid JWT signature encoding: %s", err)
+		return
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(s.publicKey, crypto.SHA256, hash[:], signature); err != nil {
+		s.t.Errorf("Invalid JWT signature: %s", err)
+	}
+
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var c map[string]interface{}
+	if err := json.Unmarshal(claims, &c); err != nil {
+		s.t.Errorf("Invalid JWT claims: %s", err)
+		return
+	}
+	if c["iss"] != "bot@project.iam.gserviceaccount.com" || c["scope"] != chatBotScope || c["aud"] != s.server.URL+"/token" {
+		s.t.Errorf("Unexpected JWT claims: %v", c)
+	}
+}
+
+func testServiceAccountKey(t *testing.T) (string, *rsa.PublicKey) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %s", err)
+	}
+
+	key, err := json.Marshal(ServiceAccountKey{
+		ClientEmail:  "bot@project.iam.gserviceaccount.com",
+		PrivateKeyID: "key-id",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal service account key: %s", err)
+	}
+
+	return string(key), &privateKey.PublicKey
+}
+
+func Test_callAPI(t *testing.T) {
+	key, publicKey := testServiceAccountKey(t)
+	msg := googlechat.Message{Text: "text", Thread: &googlechat.Thread{ThreadKey: "builds"}}
+
+	tests := []struct {
+		name     string
+		config   Config
+		output   *googlechat.MessageResponse
+		requests []string
+	}{
+		{
+			name:   "Create message",
+			config: Config{APIAction: createAction, Space: "spaces/AAA", ThreadReplyOption: "reply_or_fail"},
+			output: &googlechat.MessageResponse{
+				Name:   "spaces/AAA/messages/BBB",
+				Thread: &googlechat.Thread{Name: "spaces/AAA/threads/CCC"},
+				Space:  &googlechat.Space{Name: "spaces/AAA"},
+			},
+			requests: []string{`POST /v1/spaces/AAA/messages?messageReplyOption=REPLY_MESSAGE_OR_FAIL&threadKey=builds {"text":"text","thread":{"threadKey":"builds"}}`},
+		},
+		{
+			name:   "Update message",
+			config: Config{APIAction: updateAction, MessageName: "spaces/AAA/messages/BBB"},
+			output: &googlechat.MessageResponse{
+				Name:   "spaces/AAA/messages/BBB",
+				Thread: &googlechat.Thread{Name: "spaces/AAA/threads/CCC"},
+				Space:  &googlechat.Space{Name: "spaces/AAA"},
+			},
+			requests: []string{`PATCH /v1/spaces/AAA/messages/BBB?updateMask=text%2Ccards {"text":"text","thread":{"threadKey":"builds"}}`},
+		},
+		{
+			name:     "Delete message",
+			config:   Config{APIAction: deleteAction, MessageName: "spaces/AAA/messages/BBB"},
+			requests: []string{`DELETE /v1/spaces/AAA/messages/BBB `},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := newStubAPI(t, publicKey)
+			defer stub.server.Close()
+
+			tc.config.Transport = apiTransport
+			tc.config.ServiceAccountKey = stepconf.Secret(key)
+			tc.config.TokenURL = stub.server.URL + "/token"
+			tc.config.APIBaseURL = stub.server.URL
+			tc.config.RetryMaxAttempts = 1
+
+			resp, err := callAPI(tc.config, msg)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if !cmp.Equal(resp, tc.output) {
+				t.Errorf("Returned response is not correct: expected %+v, got %+v", tc.output, resp)
+			}
+
+			if !cmp.Equal(stub.requests, tc.requests) {
+				t.Errorf("Requests are not correct:\nexpected: %v\ngot:      %v", tc.requests, stub.requests)
+			}
+
+			if stub.tokens != 1 {
+				t.Errorf("Unexpected number of token requests: %d", stub.tokens)
+			}
+		})
+	}
+}
+
+func Test_APIClient_token_cached(t *testing.T) {
+	key, publicKey := testServiceAccountKey(t)
+	stub := newStubAPI(t, publicKey)
+	defer stub.server.Close()
+
+	client, err := NewAPIClient(Config{
+		ServiceAccountKey: stepconf.Secret(key),
+		TokenURL:          stub.server.URL + "/token",
+		APIBaseURL:        stub.server.URL,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.CreateMessage("spaces/AAA", googlechat.Message{Text: "text"}, ""); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	}
+
+	if stub.tokens != 1 {
+		t.Errorf("Access token is not cached, requested %d tokens", stub.tokens)
+	}
+}
+
+func Test_APIClient_token_retried(t *testing.T) {
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { sleep = time.Sleep }()
+
+	key, publicKey := testServiceAccountKey(t)
+	stub := newStubAPI(t, publicKey)
+	stub.tokenErrors = 1
+	defer stub.server.Close()
+
+	client, err := NewAPIClient(Config{
+		ServiceAccountKey: stepconf.Secret(key),
+		TokenURL:          stub.server.URL + "/token",
+		APIBaseURL:        stub.server.URL,
+		RetryMaxAttempts:  3,
+		RetryBaseDelay:    1,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, err := client.CreateMessage("spaces/AAA", googlechat.Message{Text: "text"}, ""); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	if stub.tokens != 2 {
+		t.Errorf("Unexpected number of token requests: %d", stub.tokens)
+	}
+	if len(stub.requests) != 1 {
+		t.Errorf("Unexpected requests: %v", stub.requests)
+	}
+	if !cmp.Equal(waits, []time.Duration{time.Second}) {
+		t.Errorf("Unexpected waits: %v", waits)
+	}
+}
+
+func Test_validateAPIConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		err    string
+	}{
+		{
+			name:   "No service account key",
+			config: &Config{Transport: apiTransport, Space: "spaces/AAA"},
+			err:    "ServiceAccountKey is empty. You need to provide one when using the api transport",
+		},
+		{
+			name:   "Create without space",
+			config: &Config{Transport: apiTransport, ServiceAccountKey: "{}", APIAction: createAction},
+			err:    "Space is empty. You need to provide one to create a message",
+		},
+		{
+			name:   "Update without message name",
+			config: &Config{Transport: apiTransport, ServiceAccountKey: "{}", APIAction: updateAction},
+			err:    "MessageName is empty. You need to provide one to update a message",
+		},
+		{
+			name:   "Delete",
+			config: &Config{Transport: apiTransport, ServiceAccountKey: "{}", APIAction: deleteAction, MessageName: "spaces/AAA/messages/BBB"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateAPIConfig(tc.config)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// BuildStatus is the status of the build the message is sent for
+type BuildStatus string
+
+// Values of BuildStatus
+const (
+	statusSuccess BuildStatus = "success"
+	statusFailed  BuildStatus = "failed"
+	statusAborted BuildStatus = "aborted"
+)
+
+// BuildState is the status of the build compared to the previous build of the same workflow and branch
+type BuildState string
+
+// Values of BuildState
+const (
+	stateSuccess      BuildState = "success"
+	stateFailure      BuildState = "failure"
+	stateFixed        BuildState = "fixed"
+	stateStillFailing BuildState = "still_failing"
+	stateAborted      BuildState = "aborted"
+)
+
+// buildStates are the states in the order they are documented
+var buildStates = []BuildState{stateSuccess, stateFailure, stateFixed, stateStillFailing, stateAborted}
+
+// BuildContext describes the build the message is sent for. It is also the data of the thread_key template.
+type BuildContext struct {
+	// Provider is the CI provider running the build, e.g. bitrise
+	Provider string
+	Status   BuildStatus
+	// State is the status compared to the previous build, see resolveState
+	State BuildState
+	// PreviousStatus is the status of the previous build, empty if unknown
+	PreviousStatus BuildStatus
+	Workflow       string
+	Branch         string
+	Tag            string
+	Commit         string
+	CommitMessage  string
+	CommitAuthor   string
+	PullRequest    string
+	BuildNumber    string
+	BuildURL       string
+	AppSlug        string
+	AppTitle       string
+	AppURL         string
+	// TriggeredAt is the time the build was triggered, zero if unknown
+	TriggeredAt time.Time
+	// Now is the time the context was read
+	Now time.Time
+}
+
+// Succeeded returns true if the build is successful. Builds with an unknown status are treated as failed.
+func (b BuildContext) Succeeded() bool {
+	return b.Status == statusSuccess
+}
+
+// parseBuildStatus parses the status reported by a CI provider or the build_status input. Unknown statuses are treated as failed.
+func parseBuildStatus(s string) BuildStatus {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "0", "success", "succeeded", "passed":
+		return statusSuccess
+	case "aborted", "canceled", "cancelled":
+		return statusAborted
+	default:
+		return statusFailed
+	}
+}
+
+// resolveState compares the status of the build to the status of the previous build, which is empty if unknown
+func resolveState(status, previous BuildStatus) BuildState {
+	switch {
+	case status == statusAborted:
+		return stateAborted
+	case status == statusSuccess && previous == statusFailed:
+		return stateFixed
+	case status == statusSuccess:
+		return stateSuccess
+	case previous == statusFailed:
+		return stateStillFailing
+	default:
+		return stateFailure
+	}
+}
+
+// BuildContextProvider reads the context of the current build
+type BuildContextProvider interface {
+	BuildContext() BuildContext
+}
+
+// firstNonEmpty returns the first value which is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
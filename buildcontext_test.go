@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+// testBuildContext returns the context of a successful or failed build
+func testBuildContext(success bool) BuildContext {
+	if success {
+		return BuildContext{Status: statusSuccess, State: stateSuccess}
+	}
+	return BuildContext{Status: statusFailed, State: stateFailure}
+}
+
+func Test_parseBuildStatus(t *testing.T) {
+	tests := []struct {
+		input  string
+		output BuildStatus
+	}{
+		{input: "0", output: statusSuccess},
+		{input: "success", output: statusSuccess},
+		{input: " Passed ", output: statusSuccess},
+		{input: "Cancelled", output: statusAborted},
+		{input: "1", output: statusFailed},
+		{input: "failure", output: statusFailed},
+		{input: "", output: statusFailed},
+	}
+
+	for _, tc := range tests {
+		if status := parseBuildStatus(tc.input); status != tc.output {
+			t.Errorf("Status of %q is not correct: expected %s, got %s", tc.input, tc.output, status)
+		}
+	}
+}
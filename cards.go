@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+)
+
+// Values of the card_version input
+const (
+	cardsV1 = "v1"
+	cardsV2 = "v2"
+)
+
+// CardInput defines the input format of a card of the cards input. The fields mirror the single card inputs
+type CardInput struct {
+	Title             string `json:"title,omitempty"`
+	TitleOnError      string `json:"titleOnError,omitempty"`
+	Subtitle          string `json:"subtitle,omitempty"`
+	SubtitleOnError   string `json:"subtitleOnError,omitempty"`
+	ImageURL          string `json:"image,omitempty"`
+	ImageURLOnError   string `json:"imageOnError,omitempty"`
+	ImageStyle        string `json:"imageStyle,omitempty"`
+	ImageStyleOnError string `json:"imageStyleOnError,omitempty"`
+	// When the card is shown: "always" (default), "success" or "failure"
+	When     string         `json:"when,omitempty"`
+	Sections []SectionInput `json:"sections"`
+	// SectionsOnError replace Sections if the build failed
+	SectionsOnError []SectionInput `json:"sectionsOnError,omitempty"`
+}
+
+// ParseCards parses a JSON array of cards. Cards which should not be shown for the build status are omitted
+func ParseCards(raw string, build BuildContext) (cards []googlechat.Card, err error) {
+	if raw == "" {
+		return
+	}
+
+	var cardInputs []CardInput
+	if err = json.Unmarshal([]byte(raw), &cardInputs); err != nil {
+		err = fmt.Errorf("Could not parse cards: %s", err)
+		return
+	}
+
+	for i, input := range cardInputs {
+		var shown bool
+		shown, err = isShown(input.When, build)
+		if err != nil {
+			err = fmt.Errorf("cards[%d]: %s", i, err)
+			return
+		}
+		if !shown {
+			continue
+		}
+
+		if input.ImageStyle != "" && input.ImageStyle != "square" && input.ImageStyle != "circular" {
+			err = fmt.Errorf("cards[%d]: unknown imageStyle %s, expected square or circular", i, input.ImageStyle)
+			return
+		}
+		if input.ImageStyleOnError != "" && input.ImageStyleOnError != "square" && input.ImageStyleOnError != "circular" {
+			err = fmt.Errorf("cards[%d]: unknown imageStyleOnError %s, expected square or circular", i, input.ImageStyleOnError)
+			return
+		}
+
+		sectionInputs, path := input.Sections, fmt.Sprintf("cards[%d].sections", i)
+		if !build.Succeeded() && len(input.SectionsOnError) > 0 {
+			sectionInputs, path = input.SectionsOnError, fmt.Sprintf("cards[%d].sectionsOnError", i)
+		}
+
+		if len(sectionInputs) == 0 {
+			err = fmt.Errorf("cards[%d]: a card should have at least one section", i)
+			return
+		}
+
+		var sections []googlechat.Section
+		sections, err = parseSectionInputs(sectionInputs, path, build)
+		if err != nil {
+			return
+		}
+		// Every section is hidden for the build status
+		if len(sections) == 0 {
+			continue
+		}
+
+		cards = append(cards, googlechat.Card{
+			Header: googlechat.CreateHeader(
+				selectValue(build, input.Title, input.TitleOnError),
+				selectValue(build, input.Subtitle, input.SubtitleOnError),
+				selectValue(build, input.ImageURL, input.ImageURLOnError),
+				selectValue(build, input.ImageStyle, input.ImageStyleOnError),
+			),
+			Sections: sections,
+		})
+	}
+
+	return
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// envPrefix is the prefix of the environment variables which can be used instead of the step inputs, e.g. GCHAT_WEBHOOK_URL
+const envPrefix = "GCHAT_"
+
+// inputDefaults are the default values of the inputs in step.yml. Bitrise sets them before running the step,
+// elsewhere they are applied by applyConfigSources.
+var inputDefaults = map[string]string{
+	"preset":                            "none",
+	"image_style":                       "square",
+	"image_style_on_error":              "square",
+	"card_version":                      "v1",
+	"thread_reply_option":               "fallback_to_new_thread",
+	"convert_simple_to_advanced_format": "no",
+	"convert_advanced_to_simple_format": "no",
+	"markdown":                          "no",
+	"escape_values":                     "no",
+	"transport":                         "webhook",
+	"api_action":                        "create",
+	"api_base_url":                      "https://chat.googleapis.com",
+	"retry_max_attempts":                "3",
+	"retry_base_delay":                  "1",
+	"retry_jitter":                      "yes",
+	"retry_max_time":                    "60",
+	"concurrency":                       "4",
+	"fail_on_target_error":              "any",
+	"mode":                              "send",
+	"failure_policy":                    "fail",
+	"dry_run":                           "no",
+	"is_debug_mode":                     "no",
+	"send_when":                         "always",
+	"trigger_filter":                    "any",
+}
+
+// configInput is an input of the step, read into a field of the Config
+type configInput struct {
+	// Name of the input, e.g. webhook_url
+	Name string
+	// Bool is true for yes/no inputs
+	Bool bool
+	// Field is the index of the field of the input in the Config
+	Field int
+}
+
+// configInputs returns the inputs of the Config. Environment variables set by Bitrise, like BITRISE_DEPLOY_DIR, are omitted.
+func configInputs() []configInput {
+	var inputs []configInput
+
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := strings.Split(field.Tag.Get("env"), ",")[0]
+		if name == "" || name != strings.ToLower(name) {
+			continue
+		}
+
+		inputs = append(inputs, configInput{
+			Name:  name,
+			Bool:  field.Type.Kind() == reflect.Bool,
+			Field: i,
+		})
+	}
+
+	return inputs
+}
+
+// flagName returns the command line flag of an input, e.g. --webhook-url for webhook_url
+func flagName(input string) string {
+	return strings.Replace(input, "_", "-", -1)
+}
+
+// yesNoFlag is the flag of a yes/no input, which can be given without a value
+type yesNoFlag struct {
+	value *string
+}
+
+func (f yesNoFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return *f.value
+}
+
+func (f yesNoFlag) Set(s string) error {
+	switch strings.ToLower(s) {
+	case "yes", "true", "1":
+		*f.value = "yes"
+	case "no", "false", "0":
+		*f.value = "no"
+	default:
+		return fmt.Errorf("expected yes or no, got %s", s)
+	}
+	return nil
+}
+
+func (f yesNoFlag) IsBoolFlag() bool {
+	return true
+}
+
+// applyConfigSources makes the inputs readable from command line flags and GCHAT_ prefixed environment variables,
+// so the step can also run outside Bitrise. The values are set as the environment variables read by stepconf.
+// Flags take precedence over the inputs, which take precedence over the prefixed variables and the defaults of step.yml.
+// It returns the arguments which are not flags, e.g. resend.
+func applyConfigSources(args []string, getenv func(string) string, setenv func(string, string) error) ([]string, error) {
+	inputs := configInputs()
+
+	flags := flag.NewFlagSet("google-chat", flag.ContinueOnError)
+	values := map[string]*string{}
+	for _, input := range inputs {
+		value := new(string)
+		values[input.Name] = value
+
+		usage := fmt.Sprintf("sets the %s input, also read from %s%s", input.Name, envPrefix, strings.ToUpper(input.Name))
+		if input.Bool {
+			flags.Var(yesNoFlag{value: value}, flagName(input.Name), usage)
+		} else {
+			flags.StringVar(value, flagName(input.Name), "", usage)
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	for _, input := range inputs {
+		var value string
+		switch {
+		case given[flagName(input.Name)]:
+			value = *values[input.Name]
+		case getenv(input.Name) != "":
+			continue
+		default:
+			value = firstNonEmpty(getenv(envPrefix+strings.ToUpper(input.Name)), inputDefaults[input.Name])
+			if value == "" {
+				continue
+			}
+		}
+
+		if err := setenv(input.Name, value); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %s", input.Name, err)
+		}
+	}
+
+	return flags.Args(), nil
+}
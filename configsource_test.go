@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_applyConfigSources(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		env    map[string]string
+		output map[string]string
+		rest   []string
+		err    string
+	}{
+		{
+			name:   "Flags",
+			args:   []string{"--webhook-url", "https://example.org/hook", "--dry-run", "--text=Build passed"},
+			output: map[string]string{"webhook_url": "https://example.org/hook", "dry_run": "yes", "text": "Build passed"},
+		},
+		{
+			name:   "Prefixed environment variables",
+			env:    map[string]string{"GCHAT_WEBHOOK_URL": "https://example.org/hook", "GCHAT_BUILD_STATUS": "success"},
+			output: map[string]string{"webhook_url": "https://example.org/hook", "build_status": "success"},
+		},
+		{
+			name:   "Inputs take precedence over prefixed variables and defaults",
+			env:    map[string]string{"text": "input", "GCHAT_TEXT": "prefixed", "GCHAT_TITLE": "title", "mode": "resend"},
+			output: map[string]string{"title": "title"},
+		},
+		{
+			name:   "Flags take precedence over inputs",
+			args:   []string{"--text", "flag", "--dry-run=no", "--card-version", "v2"},
+			env:    map[string]string{"text": "input", "card_version": "v1"},
+			output: map[string]string{"text": "flag", "dry_run": "no", "card_version": "v2"},
+		},
+		{
+			name:   "Positional arguments",
+			args:   []string{"--spool-dir", "/tmp/spool", "resend"},
+			output: map[string]string{"spool_dir": "/tmp/spool"},
+			rest:   []string{"resend"},
+		},
+		{
+			name: "Unknown flag",
+			args: []string{"--unknown", "value"},
+			err:  "flag provided but not defined: -unknown",
+		},
+		{
+			name: "Environment variables set by Bitrise have no flag",
+			args: []string{"--BITRISE-DEPLOY-DIR", "/tmp"},
+			err:  "flag provided but not defined: -BITRISE-DEPLOY-DIR",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			set := map[string]string{}
+			getenv := func(key string) string { return tc.env[key] }
+			setenv := func(key, value string) error {
+				set[key] = value
+				return nil
+			}
+
+			rest, err := applyConfigSources(tc.args, getenv, setenv)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			// Inputs without a value get the default of step.yml
+			expected := map[string]string{}
+			for name, value := range inputDefaults {
+				if tc.env[name] == "" {
+					expected[name] = value
+				}
+			}
+			for name, value := range tc.output {
+				expected[name] = value
+			}
+
+			if !cmp.Equal(set, expected) {
+				t.Errorf("Set inputs are not correct: %s", cmp.Diff(expected, set))
+			}
+			if len(rest) != len(tc.rest) || (len(rest) > 0 && !cmp.Equal(rest, tc.rest)) {
+				t.Errorf("Remaining arguments are not correct: expected %v, got %v", tc.rest, rest)
+			}
+		})
+	}
+}
+
+func Test_inputDefaults(t *testing.T) {
+	b, err := ioutil.ReadFile("step.yml")
+	if err != nil {
+		t.Fatalf("Failed to read step.yml: %s", err)
+	}
+
+	defaults := map[string]string{}
+	for _, match := range regexp.MustCompile(`(?m)^  - ([a-z_]+): (.+)$`).FindAllStringSubmatch(string(b), -1) {
+		defaults[match[1]] = strings.Trim(match[2], `"`)
+	}
+
+	if !cmp.Equal(inputDefaults, defaults) {
+		t.Errorf("Defaults don't match step.yml: %s", cmp.Diff(defaults, inputDefaults))
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// payloadFileName is the name of the file the message is written to in dry run mode
+const payloadFileName = "google-chat-message.json"
+
+// writePayload pretty-prints the message and writes it to the directory, returning the path of the file
+func writePayload(msg googlechat.Message, dir string) (string, error) {
+	b, err := json.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("%s", b)
+
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %s", dir, err)
+	}
+
+	path := filepath.Join(dir, payloadFileName)
+	if err := ioutil.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write the message to %s: %s", path, err)
+	}
+
+	return path, nil
+}
+
+// dryRun writes the message instead of sending it and exports the path of the file
+func dryRun(conf Config, msg googlechat.Message) error {
+	log.Infof("Dry run, the message is not sent:")
+
+	path, err := writePayload(msg, conf.DeployDir)
+	if err != nil {
+		return err
+	}
+
+	log.Donef("Message written to %s", path)
+
+	return exportOutput(payloadPathOutput, path)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+)
+
+func Test_dryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dry-run")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+
+	exported := map[string]string{}
+	exportOutput = func(key, value string) error {
+		exported[key] = value
+		return nil
+	}
+	defer func() { exportOutput = exportEnvironmentWithEnvman }()
+
+	msg := googlechat.Message{
+		Text: "text",
+		Cards: []googlechat.Card{{
+			Sections: []googlechat.Section{{
+				Widgets: []*googlechat.Widget{{
+					TextParagraph: &googlechat.TextParagraph{Text: "text"},
+				}},
+			}},
+		}},
+	}
+
+	if err := dryRun(Config{DeployDir: dir}, msg); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	path := filepath.Join(dir, payloadFileName)
+	if exported[payloadPathOutput] != path {
+		t.Errorf("Exported path is not correct: expected %s, got %s", path, exported[payloadPathOutput])
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read payload: %s", err)
+	}
+
+	expected := `{
+  "text": "text",
+  "cards": [
+    {
+      "sections": [
+        {
+          "widgets": [
+            {
+              "textParagraph": {
+                "text": "text"
+              }
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}
+`
+	if string(b) != expected {
+		t.Errorf("Written payload is not correct:\nexpected: %s\ngot:      %s", expected, b)
+	}
+}
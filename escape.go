@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// escapedInputs are the inputs shown as card text, in which the values of the build are escaped when escape_values is enabled
+var escapedInputs = []string{"text", "key_value"}
+
+// untrustedEnvs are the environment variables written by people, which can contain markup and are substituted into inputs,
+// e.g. $GIT_CLONE_COMMIT_MESSAGE_SUBJECT. On Bitrise the commit message of a pull request build is its title and description.
+var untrustedEnvs = []string{
+	"BITRISE_GIT_MESSAGE",
+	"GIT_CLONE_COMMIT_MESSAGE_SUBJECT",
+	"GIT_CLONE_COMMIT_MESSAGE_BODY",
+	"GIT_CLONE_COMMIT_AUTHOR_NAME",
+	"GIT_CLONE_COMMIT_AUTHOR_EMAIL",
+	"GIT_CLONE_COMMIT_COMMITER_NAME",
+	"GIT_CLONE_COMMIT_COMMITER_EMAIL",
+	"BITRISE_GIT_BRANCH",
+	"BITRISEIO_GIT_BRANCH_DEST",
+	"BITRISE_GIT_TAG",
+}
+
+// untrustedValues returns the values written by people, which can contain markup: the untrusted environment variables and
+// the values of the build, e.g. the commit message. Their lines are also returned separately, as e.g. only the subject of
+// the commit message might be substituted.
+func untrustedValues(build BuildContext, getenv func(string) string) []string {
+	values := []string{
+		build.CommitMessage,
+		build.CommitAuthor,
+		build.Branch,
+		build.Tag,
+		build.Workflow,
+		build.AppTitle,
+	}
+	for _, env := range untrustedEnvs {
+		values = append(values, getenv(env))
+	}
+
+	for _, value := range values {
+		if strings.Contains(value, "\n") {
+			values = append(values, strings.Split(value, "\n")...)
+		}
+	}
+	return values
+}
+
+// escapeSubstitutedValues escapes the untrusted values which were substituted into the card text inputs, e.g. $BITRISE_GIT_MESSAGE.
+// The markup of the inputs itself is kept.
+func escapeSubstitutedValues(c Config, build BuildContext, getenv func(string) string) Config {
+	values := untrustedValues(build, getenv)
+	// Longer values are replaced first, so a branch name in the commit message is not escaped separately
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	var replacements []string
+	for _, value := range values {
+		if escaped := html.EscapeString(value); escaped != value {
+			replacements = append(replacements, value, escaped)
+		}
+	}
+	if len(replacements) == 0 {
+		return c
+	}
+	replacer := strings.NewReplacer(replacements...)
+
+	v := reflect.ValueOf(&c).Elem()
+	inputs := overridableInputs()
+	for _, name := range escapedInputs {
+		field := v.Field(inputs[name].Field)
+		field.SetString(replacer.Replace(field.String()))
+	}
+
+	return c
+}
+
+// cardText is a text of a card which is shown as formatted text, named by its path in the message
+type cardText struct {
+	Path string
+	Text string
+}
+
+// cardTexts returns the formatted texts of the cards of the message: text paragraphs and the content of key values
+func cardTexts(msg googlechat.Message) []cardText {
+	var texts []cardText
+	add := func(path, text string) {
+		if text != "" {
+			texts = append(texts, cardText{Path: path, Text: text})
+		}
+	}
+
+	for i, card := range msg.Cards {
+		for j, section := range card.Sections {
+			for k, widget := range section.Widgets {
+				path := fmt.Sprintf("cards[%d].sections[%d].widgets[%d]", i, j, k)
+				if widget.TextParagraph != nil {
+					add(path+".textParagraph.text", widget.TextParagraph.Text)
+				}
+				if widget.KeyValue != nil {
+					add(path+".keyValue.content", widget.KeyValue.Content)
+				}
+			}
+		}
+	}
+
+	for i, card := range msg.CardsV2 {
+		if card.Card == nil {
+			continue
+		}
+		for j, section := range card.Card.Sections {
+			for k, widget := range section.Widgets {
+				path := fmt.Sprintf("cardsV2[%d].card.sections[%d].widgets[%d]", i, j, k)
+				if widget.TextParagraph != nil {
+					add(path+".textParagraph.text", widget.TextParagraph.Text)
+				}
+				if widget.DecoratedText != nil {
+					add(path+".decoratedText.text", widget.DecoratedText.Text)
+				}
+			}
+		}
+	}
+
+	return texts
+}
+
+// checkCardTexts returns the problems with the tags left in the card texts, which Chat renders broken or not at all
+func checkCardTexts(msg googlechat.Message) []string {
+	var problems []string
+	for _, text := range cardTexts(msg) {
+		for _, problem := range googlechat.CheckAdvancedFormatting(text.Text) {
+			problems = append(problems, fmt.Sprintf("%s: %s", text.Path, problem))
+		}
+	}
+	return problems
+}
+
+// warnCardTexts warns about the problems with the tags in the card texts, see checkCardTexts
+func warnCardTexts(msg googlechat.Message) {
+	problems := checkCardTexts(msg)
+	if len(problems) > 0 {
+		log.Warnf("The card text might not be shown correctly, enable escape_values or use escapeHTML for values with markup:\n- %s", strings.Join(problems, "\n- "))
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_escapeSubstitutedValues(t *testing.T) {
+	build := BuildContext{
+		Branch:        "fix/<T>",
+		CommitMessage: "Fix <T> generic on fix/<T>\n\nUse List<T> & Map<K, V>",
+		CommitAuthor:  "Jane & John",
+	}
+	env := map[string]string{
+		"GIT_CLONE_COMMIT_AUTHOR_EMAIL": "<jane@example.org>",
+	}
+	getenv := func(name string) string { return env[name] }
+
+	tests := []struct {
+		name   string
+		config Config
+		output Config
+	}{
+		{
+			name: "Substituted values",
+			config: Config{
+				Text:     "<b>Fix <T> generic on fix/<T></b> by Jane & John",
+				KeyValue: `[{"content":"fix/<T>"}]`,
+			},
+			output: Config{
+				Text:     "<b>Fix &lt;T&gt; generic on fix/&lt;T&gt;</b> by Jane &amp; John",
+				KeyValue: `[{"content":"fix/&lt;T&gt;"}]`,
+			},
+		},
+		{
+			name: "Subject, body and environment variables",
+			config: Config{
+				Text:     "Fix <T> generic on fix/<T> (<jane@example.org>)",
+				KeyValue: `[{"content":"Use List<T> & Map<K, V>"}]`,
+			},
+			output: Config{
+				Text:     "Fix &lt;T&gt; generic on fix/&lt;T&gt; (&lt;jane@example.org&gt;)",
+				KeyValue: `[{"content":"Use List&lt;T&gt; &amp; Map&lt;K, V&gt;"}]`,
+			},
+		},
+		{
+			name:   "Only card text inputs",
+			config: Config{Title: "fix/<T>", Message: "fix/<T>"},
+			output: Config{Title: "fix/<T>", Message: "fix/<T>"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			escaped := escapeSubstitutedValues(tc.config, build, getenv)
+
+			if !cmp.Equal(escaped, tc.output) {
+				t.Errorf("Returned config is not correct: %s", cmp.Diff(tc.output, escaped))
+			}
+		})
+	}
+}
+
+func Test_newMessage_escapeValues(t *testing.T) {
+	build := testBuildContext(true)
+	build.CommitMessage = "a < b && c"
+
+	msg, err := newMessage(Config{
+		Text:         "<i>a < b && c</i> {{ .CommitMessage }} <a href=\"{{ .BuildURL | raw }}\">build</a>",
+		EscapeValues: true,
+	}, build)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := "<i>a &lt; b &amp;&amp; c</i> a &lt; b &amp;&amp; c <a href=\"" + build.BuildURL + "\">build</a>"
+	if text := msg.Cards[0].Sections[0].Widgets[0].TextParagraph.Text; text != expected {
+		t.Errorf("Text is not escaped correctly:\nexpected: %s\ngot:      %s", expected, text)
+	}
+
+	// The message text shows the values as written
+	expected = "<i>a < b && c</i> a < b && c <a href=\"" + build.BuildURL + "\">build</a>"
+	if msg.Text != expected {
+		t.Errorf("Message text is not correct:\nexpected: %s\ngot:      %s", expected, msg.Text)
+	}
+}
+
+func Test_checkCardTexts(t *testing.T) {
+	msg := googlechat.Message{
+		Cards: []googlechat.Card{{
+			Header: &googlechat.Header{Title: "<T>"},
+			Sections: []googlechat.Section{{
+				Widgets: []*googlechat.Widget{
+					{TextParagraph: &googlechat.TextParagraph{Text: "<b>Fix <T> generic</b>"}},
+					{KeyValue: &googlechat.KeyValue{TopLabel: "<T>", Content: "<b>content"}},
+				},
+			}},
+		}},
+		CardsV2: []googlechat.CardWithID{{
+			Card: &googlechat.CardV2{
+				Sections: []googlechat.SectionV2{{
+					Widgets: []*googlechat.WidgetV2{{DecoratedText: &googlechat.DecoratedText{Text: "</i>"}}},
+				}},
+			},
+		}},
+	}
+
+	expected := []string{
+		"cards[0].sections[0].widgets[0].textParagraph.text: unsupported tag <T>",
+		"cards[0].sections[0].widgets[1].keyValue.content: <b> is not closed",
+		"cardsV2[0].card.sections[0].widgets[0].decoratedText.text: </i> has no opening tag",
+	}
+	if problems := checkCardTexts(msg); !cmp.Equal(problems, expected) {
+		t.Errorf("Returned problems are not correct: %s", cmp.Diff(expected, problems))
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Target is a webhook the message is sent to
+type Target struct {
+	// Label is used to refer to the target in the logs, as the URL contains a secret
+	Label string
+	URL   string
+}
+
+// parseTargets parses webhooks separated by newlines. Each line contains either a url,
+// or a label and a url separated by the first pipe character. Empty lines are omitted,
+// targets without a label are labeled by their line number.
+func parseTargets(s string) (targets []Target, err error) {
+	labels := map[string]bool{}
+
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		target := Target{
+			Label: fmt.Sprintf("#%d", i+1),
+			URL:   line,
+		}
+
+		if split := strings.SplitN(line, "|", 2); len(split) == 2 {
+			target.Label = strings.TrimSpace(split[0])
+			target.URL = strings.TrimSpace(split[1])
+
+			if target.Label == "" || target.URL == "" {
+				err = fmt.Errorf("Could not parse webhook on line %d, expected a url or label|url", i+1)
+				return
+			}
+		}
+
+		if labels[target.Label] {
+			err = fmt.Errorf("Webhook label %s is used more than once", target.Label)
+			return
+		}
+		labels[target.Label] = true
+
+		targets = append(targets, target)
+	}
+
+	if len(targets) == 0 {
+		err = fmt.Errorf("WebhookURL is empty. You need to provide one")
+	}
+
+	return
+}
+
+// TargetResult is the outcome of sending the message to a single target
+type TargetResult struct {
+	Target Target
+	// Response of the api, nil if sending failed
+	Response *googlechat.MessageResponse
+	Err      error
+	Latency  time.Duration
+}
+
+// sendToTargets calls send for every target using at most concurrency workers.
+// The results are returned in the order of the targets.
+func sendToTargets(targets []Target, concurrency int, send func(Target) (*googlechat.MessageResponse, error)) []TargetResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]TargetResult, len(targets))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(targets); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				start := now()
+				resp, err := send(targets[i])
+
+				results[i] = TargetResult{
+					Target:   targets[i],
+					Response: resp,
+					Err:      err,
+					Latency:  now().Sub(start),
+				}
+			}
+		}()
+	}
+
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// printResults prints a table with the outcome of every target
+func printResults(results []TargetResult) {
+	width := len("Target")
+	for _, result := range results {
+		if len(result.Target.Label) > width {
+			width = len(result.Target.Label)
+		}
+	}
+
+	log.Printf("%-*s  %-7s  %10s  %s", width, "Target", "Result", "Latency", "Error")
+	for _, result := range results {
+		status, errorMessage := "success", ""
+		if result.Err != nil {
+			status, errorMessage = "failure", result.Err.Error()
+		}
+
+		log.Printf("%-*s  %-7s  %10s  %s", width, result.Target.Label, status, result.Latency.Round(time.Millisecond), errorMessage)
+	}
+}
+
+// firstResponse returns the response of the first target the message was sent to successfully
+func firstResponse(results []TargetResult) *googlechat.MessageResponse {
+	for _, result := range results {
+		if result.Err == nil && result.Response != nil {
+			return result.Response
+		}
+	}
+	return nil
+}
+
+// Values of the fail_on_target_error input
+const (
+	failOnAnyTarget  = "any"
+	failOnAllTargets = "all"
+	failOnNever      = "never"
+)
+
+// checkResults returns an error if the failed targets should fail the step according to the policy
+func checkResults(results []TargetResult, policy string) error {
+	var failed []string
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result.Target.Label)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	if policy == failOnNever || (policy == failOnAllTargets && len(failed) < len(results)) {
+		log.Warnf("Failed to send the message to %d of %d targets: %s", len(failed), len(results), strings.Join(failed, ", "))
+		return nil
+	}
+
+	if len(results) == 1 {
+		return results[0].Err
+	}
+
+	return fmt.Errorf("failed to send the message to %d of %d targets: %s", len(failed), len(results), strings.Join(failed, ", "))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/bitrise-io/go-steputils/stepconf"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+func Test_parseTargets(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output []Target
+		err    string
+	}{
+		{
+			name:   "Single url",
+			input:  "https://chat.googleapis.com/v1/spaces/A/messages?key=k&token=t",
+			output: []Target{{Label: "#1", URL: "https://chat.googleapis.com/v1/spaces/A/messages?key=k&token=t"}},
+		},
+		{
+			name:  "Multiple urls with labels and empty lines",
+			input: "team|https://example.org/a\n\n  https://example.org/b  \nrelease | https://example.org/c\n",
+			output: []Target{
+				{Label: "team", URL: "https://example.org/a"},
+				{Label: "#3", URL: "https://example.org/b"},
+				{Label: "release", URL: "https://example.org/c"},
+			},
+		},
+		{
+			name:  "Empty",
+			input: "\n \n",
+			err:   "WebhookURL is empty. You need to provide one",
+		},
+		{
+			name:  "Missing url",
+			input: "https://example.org/a\nteam|",
+			err:   "Could not parse webhook on line 2, expected a url or label|url",
+		},
+		{
+			name:  "Missing url after empty lines",
+			input: "\nteam|https://example.org/a\n\nrelease|",
+			err:   "Could not parse webhook on line 4, expected a url or label|url",
+		},
+		{
+			name:  "Duplicate label",
+			input: "team|https://example.org/a\nteam|https://example.org/b",
+			err:   "Webhook label team is used more than once",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			targets, err := parseTargets(tc.input)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if tc.err == "" && !reflect.DeepEqual(targets, tc.output) {
+				t.Errorf("Returned targets are not correct: expected %+v, got %+v", tc.output, targets)
+			}
+		})
+	}
+}
+
+func Test_checkResults(t *testing.T) {
+	success := TargetResult{Target: Target{Label: "a"}}
+	failure := TargetResult{Target: Target{Label: "b"}, Err: errors.New("server error")}
+	otherFailure := TargetResult{Target: Target{Label: "c"}, Err: errors.New("server error")}
+
+	tests := []struct {
+		name    string
+		results []TargetResult
+		policy  string
+		err     string
+	}{
+		{name: "All succeeded", results: []TargetResult{success}, policy: failOnAnyTarget},
+		{name: "Single failure returns its error", results: []TargetResult{failure}, policy: failOnAnyTarget, err: "server error"},
+		{name: "Any failed", results: []TargetResult{success, failure, otherFailure}, policy: failOnAnyTarget, err: "failed to send the message to 2 of 3 targets: b, c"},
+		{name: "Some failed with policy all", results: []TargetResult{success, failure}, policy: failOnAllTargets},
+		{name: "All failed with policy all", results: []TargetResult{failure, otherFailure}, policy: failOnAllTargets, err: "failed to send the message to 2 of 2 targets: b, c"},
+		{name: "All failed with policy never", results: []TargetResult{failure, otherFailure}, policy: failOnNever},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkResults(tc.results, tc.policy)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func Test_postMessage_targets(t *testing.T) {
+	var requests int32
+	handler := func(status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(status)
+		}))
+	}
+
+	ok1, ok2, notFound := handler(http.StatusOK), handler(http.StatusOK), handler(http.StatusNotFound)
+	defer ok1.Close()
+	defer ok2.Close()
+	defer notFound.Close()
+
+	conf := Config{
+		WebhookURL:        stepconf.Secret("one|" + ok1.URL + "\ntwo|" + ok2.URL + "\nthree|" + notFound.URL),
+		RetryMaxAttempts:  3,
+		Concurrency:       2,
+		FailOnTargetError: failOnAnyTarget,
+	}
+
+	_, err := postMessage(conf, googlechat.Message{Text: "text"})
+	if err == nil || err.Error() != "failed to send the message to 1 of 3 targets: three" {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	if requests != 3 {
+		t.Errorf("Unexpected number of requests: expected 3, got %d", requests)
+	}
+
+	conf.FailOnTargetError = failOnAllTargets
+	if _, err := postMessage(conf, googlechat.Message{Text: "text"}); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func Test_postMessage_logsNoSecrets(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutWriter(&out)
+	defer log.SetOutWriter(os.Stdout)
+
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	unreachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unreachable.Close()
+
+	conf := Config{
+		WebhookURL:       stepconf.Secret("builds|" + unreachable.URL + "/v1/spaces/AAA/messages?key=SECRETKEY&token=SECRETTOKEN"),
+		RetryMaxAttempts: 2,
+	}
+
+	if _, err := postMessage(conf, googlechat.Message{Text: "text"}); err == nil || strings.Contains(err.Error(), "SECRET") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if strings.Contains(out.String(), "SECRET") || strings.Contains(out.String(), "key=") {
+		t.Errorf("Log contains the webhook query:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "builds: attempt 1/2 failed: failed to send the request: ") {
+		t.Errorf("Log doesn't contain the failed attempt:\n%s", out.String())
+	}
+}
@@ -0,0 +1,172 @@
+package googlechat
+
+// Values of the ImageStyle of a Header
+const (
+	ImageStyleSquare   = "square"
+	ImageStyleCircular = "circular"
+)
+
+// MessageBuilder builds a Message using chained calls
+type MessageBuilder struct {
+	msg Message
+}
+
+// NewMessageBuilder creates an empty MessageBuilder
+func NewMessageBuilder() *MessageBuilder {
+	return &MessageBuilder{}
+}
+
+// Text sets the text of the message, shown above the cards and in notifications
+func (b *MessageBuilder) Text(text string) *MessageBuilder {
+	b.msg.Text = text
+	return b
+}
+
+// ThreadKey posts the message in the thread with the given key
+func (b *MessageBuilder) ThreadKey(key string) *MessageBuilder {
+	if key == "" {
+		b.msg.Thread = nil
+	} else {
+		b.msg.Thread = &Thread{ThreadKey: key}
+	}
+	return b
+}
+
+// Card adds a card to the message
+func (b *MessageBuilder) Card(card *CardBuilder) *MessageBuilder {
+	b.msg.Cards = append(b.msg.Cards, card.Build())
+	return b
+}
+
+// Build returns the message
+func (b *MessageBuilder) Build() Message {
+	return b.msg
+}
+
+// CardBuilder builds a Card using chained calls. Widgets are added to the last section,
+// a section without header is started if the card has no section yet.
+type CardBuilder struct {
+	card Card
+}
+
+// NewCardBuilder creates an empty CardBuilder
+func NewCardBuilder() *CardBuilder {
+	return &CardBuilder{}
+}
+
+func (b *CardBuilder) header() *Header {
+	if b.card.Header == nil {
+		b.card.Header = &Header{}
+	}
+	return b.card.Header
+}
+
+// Header sets the title and subtitle of the card
+func (b *CardBuilder) Header(title, subtitle string) *CardBuilder {
+	header := b.header()
+	header.Title = title
+	header.Subtitle = subtitle
+	return b
+}
+
+// HeaderImage sets the image of the card header, imageStyle is either ImageStyleSquare or ImageStyleCircular
+func (b *CardBuilder) HeaderImage(imageURL, imageStyle string) *CardBuilder {
+	header := b.header()
+	header.ImageURL = imageURL
+	header.ImageStyle = imageStyle
+	return b
+}
+
+// Section starts a new section, the header is optional
+func (b *CardBuilder) Section(header string) *CardBuilder {
+	b.card.Sections = append(b.card.Sections, Section{Header: header})
+	return b
+}
+
+func (b *CardBuilder) section() *Section {
+	if len(b.card.Sections) == 0 {
+		b.Section("")
+	}
+	return &b.card.Sections[len(b.card.Sections)-1]
+}
+
+// Collapsible makes the last section collapsible, only showing the first widgets until expanded. Only supported by cardsV2
+func (b *CardBuilder) Collapsible(uncollapsibleWidgetsCount int) *CardBuilder {
+	section := b.section()
+	section.Collapsible = true
+	section.UncollapsibleWidgetsCount = uncollapsibleWidgetsCount
+	return b
+}
+
+// Widget adds a widget to the last section
+func (b *CardBuilder) Widget(widget *Widget) *CardBuilder {
+	section := b.section()
+	section.Widgets = append(section.Widgets, widget)
+	return b
+}
+
+// Text adds a text paragraph to the last section
+func (b *CardBuilder) Text(text string) *CardBuilder {
+	return b.Widget(&Widget{
+		TextParagraph: &TextParagraph{Text: text},
+	})
+}
+
+// KeyValue adds a key value to the last section
+func (b *CardBuilder) KeyValue(topLabel, content string) *CardBuilder {
+	return b.Widget(&Widget{
+		KeyValue: &KeyValue{
+			TopLabel: topLabel,
+			Content:  content,
+		},
+	})
+}
+
+// Image adds an image to the last section, the onClick url is optional
+func (b *CardBuilder) Image(imageURL, onClickURL string) *CardBuilder {
+	image := &Image{ImageURL: imageURL}
+	if onClickURL != "" {
+		image.OnClick = NewOpenLink(onClickURL)
+	}
+
+	return b.Widget(&Widget{Image: image})
+}
+
+// Buttons adds a row of buttons to the last section
+func (b *CardBuilder) Buttons(buttons ...*Button) *CardBuilder {
+	return b.Widget(&Widget{Buttons: buttons})
+}
+
+// Build returns the card
+func (b *CardBuilder) Build() Card {
+	return b.card
+}
+
+// NewTextButton creates a button with a text opening the url
+func NewTextButton(text, url string) *Button {
+	return &Button{
+		TextButton: &TextButton{
+			Text:    text,
+			OnClick: NewOpenLink(url),
+		},
+	}
+}
+
+// NewIconButton creates a button with a built-in icon, e.g. STAR, opening the url
+func NewIconButton(icon, url string) *Button {
+	return &Button{
+		ImageButton: &ImageButton{
+			Icon:    icon,
+			OnClick: NewOpenLink(url),
+		},
+	}
+}
+
+// NewOpenLink creates an OnClick handler opening the url
+func NewOpenLink(url string) *OnClick {
+	return &OnClick{
+		OpenLink: &OpenLink{
+			URL: url,
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package googlechat
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_MessageBuilder(t *testing.T) {
+	msg := NewMessageBuilder().
+		Text("Build passed").
+		ThreadKey("builds").
+		Card(NewCardBuilder().
+			Header("Build #42", "main").
+			HeaderImage("https://example.org/logo.png", ImageStyleCircular).
+			Text("All tests passed").
+			Section("Details").
+			Collapsible(1).
+			KeyValue("Workflow", "primary").
+			Image("https://example.org/coverage.svg", "https://example.org/coverage").
+			Buttons(NewTextButton("Open", "https://example.org/build"), NewIconButton("STAR", "https://example.org"))).
+		Build()
+
+	expected := Message{
+		Text:   "Build passed",
+		Thread: &Thread{ThreadKey: "builds"},
+		Cards: []Card{{
+			Header: &Header{
+				Title:      "Build #42",
+				Subtitle:   "main",
+				ImageURL:   "https://example.org/logo.png",
+				ImageStyle: "circular",
+			},
+			Sections: []Section{
+				{
+					Widgets: []*Widget{{TextParagraph: &TextParagraph{Text: "All tests passed"}}},
+				},
+				{
+					Header:                    "Details",
+					Collapsible:               true,
+					UncollapsibleWidgetsCount: 1,
+					Widgets: []*Widget{
+						{KeyValue: &KeyValue{TopLabel: "Workflow", Content: "primary"}},
+						{Image: &Image{
+							ImageURL: "https://example.org/coverage.svg",
+							OnClick:  &OnClick{OpenLink: &OpenLink{URL: "https://example.org/coverage"}},
+						}},
+						{Buttons: []*Button{
+							{TextButton: &TextButton{Text: "Open", OnClick: &OnClick{OpenLink: &OpenLink{URL: "https://example.org/build"}}}},
+							{ImageButton: &ImageButton{Icon: "STAR", OnClick: &OnClick{OpenLink: &OpenLink{URL: "https://example.org"}}}},
+						}},
+					},
+				},
+			},
+		}},
+	}
+
+	if !cmp.Equal(msg, expected) {
+		t.Errorf("Built message is not correct: %s", cmp.Diff(expected, msg))
+	}
+}
@@ -0,0 +1,282 @@
+package googlechat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CardWithID is a card of the cardsV2 message format
+// More info at https://developers.google.com/chat/api/reference/rest/v1/cards
+type CardWithID struct {
+	// CardID identifies the card in the message
+	CardID string  `json:"cardId,omitempty"`
+	Card   *CardV2 `json:"card"`
+}
+
+// CardV2 can contain a header and must have at least one section
+type CardV2 struct {
+	Header   *CardHeaderV2 `json:"header,omitempty"`
+	Sections []SectionV2   `json:"sections,omitempty"`
+}
+
+// CardHeaderV2 is the header of a CardV2
+type CardHeaderV2 struct {
+	Title    string `json:"title,omitempty"`
+	Subtitle string `json:"subtitle,omitempty"`
+	ImageURL string `json:"imageUrl,omitempty"`
+	// imageType is either "SQUARE" or "CIRCLE"
+	ImageType    string `json:"imageType,omitempty"`
+	ImageAltText string `json:"imageAltText,omitempty"`
+}
+
+// SectionV2 of a CardV2. Sections are separated by a horizontal line
+type SectionV2 struct {
+	Header string `json:"header,omitempty"`
+	// Collapsible sections only show the first UncollapsibleWidgetsCount widgets until expanded
+	Collapsible               bool        `json:"collapsible,omitempty"`
+	UncollapsibleWidgetsCount int         `json:"uncollapsibleWidgetsCount,omitempty"`
+	Widgets                   []*WidgetV2 `json:"widgets,omitempty"`
+}
+
+// WidgetV2 of a SectionV2. Can contain only one type of UI element
+type WidgetV2 struct {
+	TextParagraph *TextParagraph `json:"textParagraph,omitempty"`
+	DecoratedText *DecoratedText `json:"decoratedText,omitempty"`
+	Image         *ImageV2       `json:"image,omitempty"`
+	ButtonList    *ButtonList    `json:"buttonList,omitempty"`
+	Divider       *Divider       `json:"divider,omitempty"`
+	Grid          *Grid          `json:"grid,omitempty"`
+	Columns       *Columns       `json:"columns,omitempty"`
+}
+
+// DecoratedText UI element, the cardsV2 equivalent of KeyValue
+type DecoratedText struct {
+	TopLabel    string    `json:"topLabel,omitempty"`
+	Text        string    `json:"text,omitempty"`
+	BottomLabel string    `json:"bottomLabel,omitempty"`
+	WrapText    bool      `json:"wrapText,omitempty"`
+	StartIcon   *Icon     `json:"startIcon,omitempty"`
+	OnClick     *OnClick  `json:"onClick,omitempty"`
+	Button      *ButtonV2 `json:"button,omitempty"`
+}
+
+// Icon is either a built-in icon or an icon url
+type Icon struct {
+	KnownIcon string `json:"knownIcon,omitempty"`
+	IconURL   string `json:"iconUrl,omitempty"`
+	AltText   string `json:"altText,omitempty"`
+}
+
+// ImageV2 UI element
+type ImageV2 struct {
+	ImageURL string   `json:"imageUrl,omitempty"`
+	OnClick  *OnClick `json:"onClick,omitempty"`
+	AltText  string   `json:"altText,omitempty"`
+}
+
+// ButtonList UI element. Buttons are laid out horizontally
+type ButtonList struct {
+	Buttons []*ButtonV2 `json:"buttons,omitempty"`
+}
+
+// ButtonV2 UI element. Can contain a text, an icon or both
+type ButtonV2 struct {
+	Text    string   `json:"text,omitempty"`
+	Icon    *Icon    `json:"icon,omitempty"`
+	Color   *Color   `json:"color,omitempty"`
+	OnClick *OnClick `json:"onClick,omitempty"`
+	AltText string   `json:"altText,omitempty"`
+}
+
+// Color of a button, every component ranges from 0 to 1
+type Color struct {
+	Red   float64 `json:"red"`
+	Green float64 `json:"green"`
+	Blue  float64 `json:"blue"`
+}
+
+// Divider UI element, a horizontal line between widgets
+type Divider struct{}
+
+// Grid UI element
+type Grid struct {
+	Title       string     `json:"title,omitempty"`
+	ColumnCount int        `json:"columnCount,omitempty"`
+	Items       []GridItem `json:"items,omitempty"`
+	OnClick     *OnClick   `json:"onClick,omitempty"`
+}
+
+// GridItem of a Grid
+type GridItem struct {
+	ID       string          `json:"id,omitempty"`
+	Image    *ImageComponent `json:"image,omitempty"`
+	Title    string          `json:"title,omitempty"`
+	Subtitle string          `json:"subtitle,omitempty"`
+}
+
+// ImageComponent is an image in a GridItem
+type ImageComponent struct {
+	ImageURI string `json:"imageUri,omitempty"`
+	AltText  string `json:"altText,omitempty"`
+}
+
+// Columns UI element, shows up to two columns next to each other
+type Columns struct {
+	ColumnItems []Column `json:"columnItems,omitempty"`
+}
+
+// Column of a Columns element
+type Column struct {
+	HorizontalSizeStyle string      `json:"horizontalSizeStyle,omitempty"`
+	HorizontalAlignment string      `json:"horizontalAlignment,omitempty"`
+	VerticalAlignment   string      `json:"verticalAlignment,omitempty"`
+	Widgets             []*WidgetV2 `json:"widgets,omitempty"`
+}
+
+// ParseColor parses a hex color like #1a73e8 or 1a73e8
+func ParseColor(hex string) (*Color, error) {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if hex == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return nil, fmt.Errorf("Could not parse color %s, expected a hex color like #1a73e8", hex)
+	}
+
+	return &Color{
+		Red:   float64(value>>16&0xff) / 255,
+		Green: float64(value>>8&0xff) / 255,
+		Blue:  float64(value&0xff) / 255,
+	}, nil
+}
+
+// CardV2Options are the cardsV2 features which can't be expressed using the legacy cards
+type CardV2Options struct {
+	ButtonColor *Color
+}
+
+// ToCardsV2 converts the legacy cards of a message to cardsV2
+func ToCardsV2(cards []Card, opts CardV2Options) []CardWithID {
+	var converted []CardWithID
+
+	for i, card := range cards {
+		cardV2 := &CardV2{
+			Header: HeaderToV2(card.Header),
+		}
+
+		for _, section := range card.Sections {
+			sectionV2 := SectionV2{
+				Header:                    section.Header,
+				Collapsible:               section.Collapsible,
+				UncollapsibleWidgetsCount: section.UncollapsibleWidgetsCount,
+			}
+
+			for _, widget := range section.Widgets {
+				sectionV2.Widgets = append(sectionV2.Widgets, widgetToV2(widget, opts))
+			}
+
+			cardV2.Sections = append(cardV2.Sections, sectionV2)
+		}
+
+		converted = append(converted, CardWithID{
+			CardID: fmt.Sprintf("card-%d", i+1),
+			Card:   cardV2,
+		})
+	}
+
+	return converted
+}
+
+// HeaderToV2 converts the header of a legacy card to the header of a CardV2
+func HeaderToV2(header *Header) *CardHeaderV2 {
+	if header == nil {
+		return nil
+	}
+
+	headerV2 := &CardHeaderV2{
+		Title:    header.Title,
+		Subtitle: header.Subtitle,
+		ImageURL: header.ImageURL,
+	}
+
+	if header.ImageURL != "" {
+		headerV2.ImageType = "SQUARE"
+		if header.ImageStyle == "circular" {
+			headerV2.ImageType = "CIRCLE"
+		}
+	}
+
+	return headerV2
+}
+
+func widgetToV2(widget *Widget, opts CardV2Options) *WidgetV2 {
+	widgetV2 := &WidgetV2{
+		TextParagraph: widget.TextParagraph,
+	}
+
+	if widget.KeyValue != nil {
+		widgetV2.DecoratedText = &DecoratedText{
+			TopLabel:    widget.KeyValue.TopLabel,
+			Text:        widget.KeyValue.Content,
+			BottomLabel: widget.KeyValue.BottomLabel,
+			WrapText:    widget.KeyValue.ContentMultiline == "true",
+			StartIcon:   iconToV2(widget.KeyValue.Icon, widget.KeyValue.IconURL),
+			OnClick:     widget.KeyValue.OnClick,
+			Button:      buttonToV2(widget.KeyValue.Button, opts),
+		}
+	}
+
+	if widget.Image != nil {
+		widgetV2.Image = &ImageV2{
+			ImageURL: widget.Image.ImageURL,
+			OnClick:  widget.Image.OnClick,
+			AltText:  widget.Image.AltText,
+		}
+	}
+
+	if len(widget.Buttons) > 0 {
+		buttonList := &ButtonList{}
+		for _, button := range widget.Buttons {
+			buttonList.Buttons = append(buttonList.Buttons, buttonToV2(button, opts))
+		}
+		widgetV2.ButtonList = buttonList
+	}
+
+	return widgetV2
+}
+
+func buttonToV2(button *Button, opts CardV2Options) *ButtonV2 {
+	if button == nil {
+		return nil
+	}
+
+	buttonV2 := &ButtonV2{
+		Color: opts.ButtonColor,
+	}
+
+	if button.TextButton != nil {
+		buttonV2.Text = button.TextButton.Text
+		buttonV2.OnClick = button.TextButton.OnClick
+	}
+
+	if button.ImageButton != nil {
+		buttonV2.Icon = iconToV2(button.ImageButton.Icon, button.ImageButton.IconURL)
+		buttonV2.OnClick = button.ImageButton.OnClick
+	}
+
+	return buttonV2
+}
+
+func iconToV2(knownIcon, iconURL string) *Icon {
+	if knownIcon == "" && iconURL == "" {
+		return nil
+	}
+
+	return &Icon{
+		KnownIcon: knownIcon,
+		IconURL:   iconURL,
+	}
+}
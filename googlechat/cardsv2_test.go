@@ -0,0 +1,59 @@
+package googlechat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_ParseColor(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output *Color
+		err    string
+	}{
+		{name: "Empty", input: "", output: nil},
+		{name: "With hash", input: "#ff0000", output: &Color{Red: 1}},
+		{name: "Without hash", input: "00ff00", output: &Color{Green: 1}},
+		{name: "Invalid", input: "#red", err: "Could not parse color red, expected a hex color like #1a73e8"},
+		{name: "Too short", input: "#fff", err: "Could not parse color fff, expected a hex color like #1a73e8"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			color, err := ParseColor(tc.input)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if !cmp.Equal(color, tc.output) {
+				t.Errorf("Returned color is not correct: expected %+v, got %+v", tc.output, color)
+			}
+		})
+	}
+}
+
+func Test_WidgetV2_marshal(t *testing.T) {
+	widgets := []*WidgetV2{
+		{Divider: &Divider{}},
+		{Grid: &Grid{Title: "artifacts", ColumnCount: 2, Items: []GridItem{{ID: "apk", Title: "app.apk", Image: &ImageComponent{ImageURI: "https://example.com/apk.png"}}}}},
+		{Columns: &Columns{ColumnItems: []Column{{HorizontalSizeStyle: "FILL_AVAILABLE_SPACE", Widgets: []*WidgetV2{{TextParagraph: &TextParagraph{Text: "left"}}}}}}},
+	}
+
+	b, err := json.Marshal(SectionV2{Header: "details", Collapsible: true, UncollapsibleWidgetsCount: 1, Widgets: widgets})
+	if err != nil {
+		t.Fatalf("Could not marshal json: %s", err)
+	}
+
+	expected := `{"header":"details","collapsible":true,"uncollapsibleWidgetsCount":1,"widgets":[` +
+		`{"divider":{}},` +
+		`{"grid":{"title":"artifacts","columnCount":2,"items":[{"id":"apk","image":{"imageUri":"https://example.com/apk.png"},"title":"app.apk"}]}},` +
+		`{"columns":{"columnItems":[{"horizontalSizeStyle":"FILL_AVAILABLE_SPACE","widgets":[{"textParagraph":{"text":"left"}}]}]}}]}`
+
+	if string(b) != expected {
+		t.Errorf("Returned section is not correct:\nexpected: %s\ngot:      %s", expected, b)
+	}
+}
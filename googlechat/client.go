@@ -0,0 +1,162 @@
+package googlechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// Values of the messageReplyOption query parameter, used when a message is posted in a thread
+const (
+	ReplyMessageFallbackToNewThread = "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD"
+	ReplyMessageOrFail              = "REPLY_MESSAGE_OR_FAIL"
+)
+
+// Doer sends an HTTP request. It is implemented by *http.Client
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client posts messages to a Google Chat webhook
+type Client struct {
+	// WebhookURL of the space, including its key and token
+	WebhookURL string
+	// ReplyOption is the messageReplyOption used for messages with a thread key, ReplyMessageFallbackToNewThread by default
+	ReplyOption string
+	// Doer sends the requests, http.DefaultClient by default
+	Doer Doer
+}
+
+// NewClient creates a Client posting to the webhook url
+func NewClient(webhookURL string) *Client {
+	return &Client{
+		WebhookURL: webhookURL,
+		Doer:       http.DefaultClient,
+	}
+}
+
+// Send makes a single attempt to post the message. Failures to deliver the message are returned as a *DeliveryError
+func (c *Client) Send(ctx context.Context, msg Message) (*MessageResponse, error) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var threadKey string
+	if msg.Thread != nil {
+		threadKey = msg.Thread.ThreadKey
+	}
+
+	return c.SendPayload(ctx, b, threadKey)
+}
+
+// SendPayload makes a single attempt to post an already encoded message, e.g. one which was stored to be sent later.
+// The thread key is added to the webhook url, as webhooks ignore the thread of the payload.
+func (c *Client) SendPayload(ctx context.Context, payload []byte, threadKey string) (*MessageResponse, error) {
+	webhookURL, err := threadURL(c.WebhookURL, threadKey, c.ReplyOption)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		// Don't include the error, it contains the secret url
+		return nil, fmt.Errorf("failed to create the request: invalid url")
+	}
+	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+
+	doer := c.Doer
+	if doer == nil {
+		doer = http.DefaultClient
+	}
+
+	body, warnings, err := DoRequest(doer, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := ParseMessageResponse(body)
+	resp.Warnings = append(warnings, resp.Warnings...)
+	return resp, nil
+}
+
+// DoRequest sends the request and returns the response body. Failures are returned as a *DeliveryError.
+// If the request succeeded but its response could not be read, the body is nil and the problem is returned as a warning.
+func DoRequest(doer Doer, req *http.Request) (body []byte, warnings []string, err error) {
+	resp, err := doer.Do(req)
+	if err != nil {
+		// Don't include the url of the error, it contains the secret key and token of the webhook
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, nil, NewDeliveryError(fmt.Errorf("failed to send the request: %s", err), true)
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	body, err = ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if err != nil {
+			return nil, nil, &DeliveryError{
+				StatusCode: resp.StatusCode,
+				Temporary:  true,
+				err:        fmt.Errorf("server error: %s, failed to read response: %s", resp.Status, err),
+			}
+		}
+		return nil, nil, newResponseError(resp, body)
+	}
+
+	// The request succeeded, a response which can't be read should not fail the delivery
+	if err != nil {
+		return nil, []string{fmt.Sprintf("failed to read the response: %s", err)}, nil
+	}
+
+	return body, nil, nil
+}
+
+// ParseMessageResponse parses the message returned by the api. The message has already been delivered,
+// so a response which can't be parsed is returned as a warning of the response.
+func ParseMessageResponse(body []byte) *MessageResponse {
+	msgResp := &MessageResponse{}
+	if len(body) == 0 {
+		return msgResp
+	}
+
+	if err := json.Unmarshal(body, msgResp); err != nil {
+		msgResp.Warnings = append(msgResp.Warnings, fmt.Sprintf("failed to parse the response: %s", err))
+	}
+
+	return msgResp
+}
+
+// threadURL adds the thread key and reply option query parameters to a webhook url
+func threadURL(rawURL, threadKey, replyOption string) (string, error) {
+	if threadKey == "" {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// Don't include the error, it contains the secret url
+		return "", fmt.Errorf("failed to add the thread key to the webhook url: invalid url")
+	}
+
+	if replyOption == "" {
+		replyOption = ReplyMessageFallbackToNewThread
+	}
+
+	query := u.Query()
+	query.Set("threadKey", threadKey)
+	query.Set("messageReplyOption", replyOption)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
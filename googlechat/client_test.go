@@ -0,0 +1,223 @@
+package googlechat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// doerFunc adapts a function to the Doer interface
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func Test_Client_Send(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		output *MessageResponse
+	}{
+		{
+			name: "Parse created message",
+			body: `{"name":"spaces/AAA/messages/BBB","thread":{"name":"spaces/AAA/threads/CCC"},"space":{"name":"spaces/AAA","displayName":"Builds"},"text":"text"}`,
+			output: &MessageResponse{
+				Name:   "spaces/AAA/messages/BBB",
+				Thread: &Thread{Name: "spaces/AAA/threads/CCC"},
+				Space:  &Space{Name: "spaces/AAA", DisplayName: "Builds"},
+			},
+		},
+		{
+			name: "Warn about invalid response",
+			body: "not json",
+			output: &MessageResponse{
+				Warnings: []string{"failed to parse the response: invalid character 'o' in literal null (expecting 'u')"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var request string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := ioutil.ReadAll(r.Body)
+				request = r.URL.RawQuery + " " + string(b)
+				w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			msg := Message{Text: "text", Thread: &Thread{ThreadKey: "main"}}
+			resp, err := NewClient(server.URL).Send(context.Background(), msg)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if !cmp.Equal(resp, tc.output) {
+				t.Errorf("Returned response is not correct: expected %+v, got %+v", tc.output, resp)
+			}
+
+			expected := `messageReplyOption=REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD&threadKey=main {"text":"text","thread":{"threadKey":"main"}}`
+			if request != expected {
+				t.Errorf("Request is not correct:\nexpected: %s\ngot:      %s", expected, request)
+			}
+		})
+	}
+}
+
+func Test_Client_Send_errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		doer      Doer
+		status    int
+		temporary bool
+		err       string
+	}{
+		{
+			name: "Network error",
+			doer: doerFunc(func(req *http.Request) (*http.Response, error) {
+				return nil, errors.New("connection refused")
+			}),
+			temporary: true,
+			err:       "failed to send the request: connection refused",
+		},
+		{
+			name: "Network error without the secret url",
+			doer: doerFunc(func(req *http.Request) (*http.Response, error) {
+				return nil, &url.Error{Op: "Post", URL: req.URL.String(), Err: errors.New("connection refused")}
+			}),
+			temporary: true,
+			err:       "failed to send the request: connection refused",
+		},
+		{
+			name: "Rate limited",
+			doer: doerFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Status:     "429 Too Many Requests",
+					StatusCode: http.StatusTooManyRequests,
+					Header:     http.Header{"Retry-After": {"3"}},
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte("slow down"))),
+				}, nil
+			}),
+			status:    http.StatusTooManyRequests,
+			temporary: true,
+			err:       "server error: 429 Too Many Requests, response: slow down",
+		},
+		{
+			name: "Bad request",
+			doer: doerFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Status:     "400 Bad Request",
+					StatusCode: http.StatusBadRequest,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte("invalid card"))),
+				}, nil
+			}),
+			status: http.StatusBadRequest,
+			err:    "server error: 400 Bad Request, response: invalid card",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &Client{WebhookURL: "https://chat.googleapis.com/v1/spaces/A/messages", Doer: tc.doer}
+
+			_, err := client.Send(context.Background(), Message{Text: "text"})
+			deliveryErr, ok := err.(*DeliveryError)
+			if !ok {
+				t.Fatalf("Expected a DeliveryError, got %v", err)
+			}
+
+			if deliveryErr.Error() != tc.err || deliveryErr.StatusCode != tc.status || deliveryErr.Temporary != tc.temporary {
+				t.Errorf("Returned error is not correct: %+v", deliveryErr)
+			}
+		})
+	}
+}
+
+// errReader fails to read the body of a response
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func Test_Client_Send_unreadableResponse(t *testing.T) {
+	client := &Client{
+		WebhookURL: "https://chat.googleapis.com/v1/spaces/A/messages",
+		Doer: doerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				Status:     "200 OK",
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(errReader{}),
+			}, nil
+		}),
+	}
+
+	resp, err := client.Send(context.Background(), Message{Text: "text"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := &MessageResponse{Warnings: []string{"failed to read the response: connection reset"}}
+	if !cmp.Equal(resp, expected) {
+		t.Errorf("Returned response is not correct: %s", cmp.Diff(expected, resp))
+	}
+}
+
+func Test_threadURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		threadKey   string
+		replyOption string
+		output      string
+	}{
+		{
+			name:   "No thread",
+			url:    "https://chat.googleapis.com/v1/spaces/A/messages?key=k&token=t",
+			output: "https://chat.googleapis.com/v1/spaces/A/messages?key=k&token=t",
+		},
+		{
+			name:        "Fallback to new thread",
+			url:         "https://chat.googleapis.com/v1/spaces/A/messages?key=k&token=t",
+			threadKey:   "pr 42",
+			replyOption: ReplyMessageFallbackToNewThread,
+			output:      "https://chat.googleapis.com/v1/spaces/A/messages?key=k&messageReplyOption=REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD&threadKey=pr+42&token=t",
+		},
+		{
+			name:        "Reply or fail",
+			url:         "https://chat.googleapis.com/v1/spaces/A/messages?key=k&token=t",
+			threadKey:   "main",
+			replyOption: ReplyMessageOrFail,
+			output:      "https://chat.googleapis.com/v1/spaces/A/messages?key=k&messageReplyOption=REPLY_MESSAGE_OR_FAIL&threadKey=main&token=t",
+		},
+		{
+			name:      "Default reply option",
+			url:       "https://chat.googleapis.com/v1/spaces/A/messages",
+			threadKey: "main",
+			output:    "https://chat.googleapis.com/v1/spaces/A/messages?messageReplyOption=REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD&threadKey=main",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			url, err := threadURL(tc.url, tc.threadKey, tc.replyOption)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if url != tc.output {
+				t.Errorf("Returned url is not correct:\nexpected: %s\ngot:      %s", tc.output, url)
+			}
+		})
+	}
+}
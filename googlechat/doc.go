@@ -0,0 +1,21 @@
+/*
+Package googlechat builds Google Chat card messages and posts them to a webhook.
+
+	msg := googlechat.NewMessageBuilder().
+		Text("Build #42 passed").
+		ThreadKey("builds").
+		Card(googlechat.NewCardBuilder().
+			Header("Build passed", "main").
+			Section("Details").
+			KeyValue("Workflow", "primary").
+			Buttons(googlechat.NewTextButton("Open build", "https://app.bitrise.io/build/42"))).
+		Build()
+
+	resp, err := googlechat.NewClient(webhookURL).Send(ctx, msg)
+
+Send makes a single attempt, failures to deliver the message are returned as a *DeliveryError
+telling if sending it again might succeed. The package doesn't log: problems with the response of
+a delivered message, e.g. a body which can't be parsed, are returned in the Warnings of the response.
+The Doer of the Client can be replaced, e.g. to use a custom http.Client or to stub the server in tests.
+*/
+package googlechat
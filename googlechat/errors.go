@@ -0,0 +1,75 @@
+package googlechat
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// now returns the current time. Replaced in tests to control the Retry-After dates.
+var now = time.Now
+
+// DeliveryError is returned when the server did not accept a message
+type DeliveryError struct {
+	// StatusCode of the response, 0 if no response was received
+	StatusCode int
+	// RetryAfter is the wait requested by the server using the Retry-After header
+	RetryAfter time.Duration
+	// Temporary is true if sending the message again might succeed
+	Temporary bool
+
+	err error
+}
+
+// NewDeliveryError creates a DeliveryError which did not receive a response
+func NewDeliveryError(err error, temporary bool) *DeliveryError {
+	return &DeliveryError{
+		Temporary: temporary,
+		err:       err,
+	}
+}
+
+func (e *DeliveryError) Error() string {
+	return e.err.Error()
+}
+
+// WithPrefix returns a copy of the error with the prefix added to its message, e.g. the step which failed.
+// The status code and whether it can be retried are kept.
+func (e *DeliveryError) WithPrefix(prefix string) *DeliveryError {
+	wrapped := *e
+	wrapped.err = fmt.Errorf("%s: %s", prefix, e.err)
+	return &wrapped
+}
+
+// newResponseError creates a DeliveryError for a response with a non-200 status code
+func newResponseError(resp *http.Response, body []byte) *DeliveryError {
+	return &DeliveryError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		Temporary:  resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+		err:        fmt.Errorf("server error: %s, response: %s", resp.Status, body),
+	}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which can either be a number of seconds or an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now()); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,33 @@
+package googlechat
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseRetryAfter(t *testing.T) {
+	fixedNow := time.Date(2020, 6, 23, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixedNow }
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		name   string
+		input  string
+		output time.Duration
+	}{
+		{name: "Empty", input: "", output: 0},
+		{name: "Seconds", input: "120", output: 2 * time.Minute},
+		{name: "Negative seconds", input: "-1", output: 0},
+		{name: "HTTP date", input: "Tue, 23 Jun 2020 12:00:30 GMT", output: 30 * time.Second},
+		{name: "HTTP date in the past", input: "Tue, 23 Jun 2020 11:00:00 GMT", output: 0},
+		{name: "Invalid", input: "soon", output: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if wait := parseRetryAfter(tc.input); wait != tc.output {
+				t.Errorf("Returned wait is not correct: expected %s, got %s", tc.output, wait)
+			}
+		})
+	}
+}
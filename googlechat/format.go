@@ -0,0 +1,561 @@
+package googlechat
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// formatKind is the kind of a node of formatted text
+type formatKind int
+
+const (
+	textNode formatKind = iota
+	// literalNode is a delimiter which must not format the text, e.g. an escaped \* in markdown
+	literalNode
+	// rawNode is copied to the output as is, e.g. a <users/123> mention
+	rawNode
+	lineBreakNode
+	boldNode
+	italicNode
+	strikeNode
+	underlineNode
+	colorNode
+	linkNode
+	codeNode
+	codeBlockNode
+	// preformattedNode is code from markdown, shown as preformatted lines in cards. The attr of a code block is "block".
+	preformattedNode
+	// quoteNode prefixes the lines of its children
+	quoteNode
+	// unsupportedNode is a tag of the advanced format without a simple equivalent, its children are kept
+	unsupportedNode
+)
+
+// formatNode is a node of the tree both formats are parsed into
+type formatNode struct {
+	kind formatKind
+	// text of text, raw and code nodes
+	text string
+	// attr is the url of a link, the color of a font, the name of an unsupported tag or "block" for preformatted blocks
+	attr     string
+	children []*formatNode
+}
+
+// droppedFormats collects the formatting which could not be converted, without duplicates
+type droppedFormats []string
+
+func (d *droppedFormats) add(format string) {
+	for _, dropped := range *d {
+		if dropped == format {
+			return
+		}
+	}
+	*d = append(*d, format)
+}
+
+// simpleDelimiters are the delimiters of the spans of the simple format
+var simpleDelimiters = map[rune]formatKind{
+	'*': boldNode,
+	'_': italicNode,
+	'~': strikeNode,
+}
+
+// zeroWidthSpace is written around literal delimiters in the simple format, which has no escaping, so they are not followed
+// or preceded by text and don't format it
+const zeroWidthSpace = '\u200b'
+
+func isSpaceRune(r rune) bool {
+	return unicode.IsSpace(r) || r == zeroWidthSpace
+}
+
+// simpleParser parses the simple format: *bold*, _italic_, ~strike~, `code`, ```code blocks```, <url|links> and <mentions>
+type simpleParser struct {
+	s []rune
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// opens returns true if the delimiter at i can open a span: it is followed by text and not preceded by a letter or digit,
+// so snake_case_names are not formatted
+func (p *simpleParser) opens(i, end int) bool {
+	return i+1 < end && !isSpaceRune(p.s[i+1]) && p.s[i+1] != p.s[i] && (i == 0 || !isWordRune(p.s[i-1]))
+}
+
+// closes returns true if the delimiter at i can close a span: it follows text and is not followed by a letter or digit
+func (p *simpleParser) closes(i int) bool {
+	return i > 0 && !isSpaceRune(p.s[i-1]) && p.s[i-1] != p.s[i] && (i+1 == len(p.s) || !isWordRune(p.s[i+1]))
+}
+
+func (p *simpleParser) hasPrefix(i int, prefix string) bool {
+	return strings.HasPrefix(string(p.s[i:]), prefix)
+}
+
+// find returns the index of the next occurrence of substr from i before end, or -1. Unless multiline is set the search stops at a newline.
+func (p *simpleParser) find(i, end int, substr string, multiline bool) int {
+	rs := []rune(substr)
+	for j := i; j+len(rs) <= end; j++ {
+		if !multiline && p.s[j] == '\n' {
+			return -1
+		}
+		if string(p.s[j:j+len(rs)]) == substr {
+			return j
+		}
+	}
+	return -1
+}
+
+// parse parses the text from pos until end. If closer is set, it parses the content of a span and stops at its closing delimiter,
+// returning false if the span is not closed on the same line. enclosing are the delimiters of the spans around it: if one of them
+// closes first the span is not closed, so spans are always nested.
+func (p *simpleParser) parse(pos, end int, closer rune, enclosing string) ([]*formatNode, int, bool) {
+	var nodes []*formatNode
+	var text []rune
+
+	flush := func() {
+		if len(text) > 0 {
+			nodes = append(nodes, &formatNode{kind: textNode, text: string(text)})
+			text = nil
+		}
+	}
+	add := func(node *formatNode) {
+		flush()
+		nodes = append(nodes, node)
+	}
+
+	for i := pos; i < end; {
+		c := p.s[i]
+
+		switch {
+		case c == '\n' && closer != 0:
+			return nil, i, false
+
+		case p.hasPrefix(i, "```"):
+			if j := p.find(i+3, end, "```", true); j >= 0 {
+				add(&formatNode{kind: codeBlockNode, text: string(p.s[i+3 : j])})
+				i = j + 3
+				continue
+			}
+			text = append(text, p.s[i:i+3]...)
+			i += 3
+			continue
+
+		case c == '`':
+			if j := p.find(i+1, end, "`", false); j > i+1 {
+				add(&formatNode{kind: codeNode, text: string(p.s[i+1 : j])})
+				i = j + 1
+				continue
+			}
+
+		case c == '<':
+			// Tags of the advanced format are kept, so both formats can be mixed
+			if tag := tagPattern.FindStringSubmatch(string(p.s[i:end])); tag != nil && supportedTags[strings.ToLower(tag[2])] {
+				add(&formatNode{kind: rawNode, text: tag[0]})
+				i += len([]rune(tag[0]))
+				continue
+			}
+			if j := p.find(i+1, end, ">", false); j > i+1 {
+				content := string(p.s[i+1 : j])
+				if bar := strings.IndexRune(content, '|'); bar > 0 && isLink(content[:bar]) {
+					labelStart := i + 1 + len([]rune(content[:bar])) + 1
+					children, _, _ := p.parse(labelStart, j, 0, "")
+					add(&formatNode{kind: linkNode, attr: content[:bar], children: children})
+					i = j + 1
+					continue
+				}
+				if isLink(content) {
+					add(&formatNode{kind: linkNode, attr: content, children: []*formatNode{{kind: textNode, text: content}}})
+					i = j + 1
+					continue
+				}
+				if strings.HasPrefix(content, "users/") && !strings.ContainsAny(content, " \t") {
+					add(&formatNode{kind: rawNode, text: "<" + content + ">"})
+					i = j + 1
+					continue
+				}
+			}
+
+		case (p.hasPrefix(i, "http://") || p.hasPrefix(i, "https://")) && (i == 0 || !isWordRune(p.s[i-1])):
+			// Urls are not formatted, except for the closing delimiter of the span they end
+			j := i
+			for j < end && !unicode.IsSpace(p.s[j]) {
+				j++
+			}
+			for closer != 0 && j > i && p.s[j-1] == closer {
+				j--
+			}
+			text = append(text, p.s[i:j]...)
+			i = j
+			continue
+
+		case simpleDelimiters[c] != textNode:
+			if c == closer && p.closes(i) && (len(nodes) > 0 || len(text) > 0) {
+				flush()
+				return nodes, i + 1, true
+			}
+			if strings.ContainsRune(enclosing, c) && p.closes(i) {
+				return nil, i, false
+			}
+			if p.opens(i, end) {
+				children, next, ok := p.parse(i+1, end, c, enclosing+string(closer))
+				if ok {
+					add(&formatNode{kind: simpleDelimiters[c], children: children})
+					i = next
+					continue
+				}
+			}
+		}
+
+		text = append(text, c)
+		i++
+	}
+
+	if closer != 0 {
+		return nil, end, false
+	}
+
+	flush()
+	return nodes, end, true
+}
+
+// isLink returns true if s is an url which can be linked
+func isLink(s string) bool {
+	return !strings.ContainsAny(s, " \t") && (strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "mailto:"))
+}
+
+// parseSimple parses text in the simple format
+func parseSimple(s string) []*formatNode {
+	p := &simpleParser{s: []rune(s)}
+	nodes, _, _ := p.parse(0, len(p.s), 0, "")
+	return nodes
+}
+
+var (
+	tagPattern  = regexp.MustCompile(`^<(/?)([a-zA-Z]+)((?:\s[^<>]*)?)/?>`)
+	attrPattern = regexp.MustCompile(`([a-zA-Z]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// advancedTags map the tags of the advanced format to their kind
+var advancedTags = map[string]formatKind{
+	"b":      boldNode,
+	"i":      italicNode,
+	"strike": strikeNode,
+	"u":      underlineNode,
+	"font":   colorNode,
+	"a":      linkNode,
+}
+
+// tagAttr returns the value of an attribute of a tag, e.g. the href of <a href="...">
+func tagAttr(attrs, name string) string {
+	for _, match := range attrPattern.FindAllStringSubmatch(attrs, -1) {
+		if strings.EqualFold(match[1], name) {
+			return html.UnescapeString(match[2] + match[3] + match[4])
+		}
+	}
+	return ""
+}
+
+// parseAdvanced parses text in the advanced format, a subset of HTML. Tags which are not closed are closed at the end,
+// closing tags without an opening tag are ignored.
+func parseAdvanced(s string) []*formatNode {
+	root := &formatNode{}
+	stack := []*formatNode{root}
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			top := stack[len(stack)-1]
+			top.children = append(top.children, &formatNode{kind: textNode, text: html.UnescapeString(text.String())})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		match := tagPattern.FindStringSubmatch(s[i:])
+		if s[i] != '<' || match == nil {
+			text.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		flush()
+		i += len(match[0])
+		name := strings.ToLower(match[2])
+
+		if match[1] == "/" {
+			for j := len(stack) - 1; j > 0; j-- {
+				if stack[j].kind == advancedTagKind(name) && (stack[j].kind != unsupportedNode || stack[j].attr == name) {
+					stack = stack[:j]
+					break
+				}
+			}
+			continue
+		}
+
+		top := stack[len(stack)-1]
+		if name == "br" {
+			top.children = append(top.children, &formatNode{kind: lineBreakNode})
+			continue
+		}
+
+		node := &formatNode{kind: advancedTagKind(name)}
+		switch node.kind {
+		case linkNode:
+			node.attr = tagAttr(match[3], "href")
+		case colorNode:
+			node.attr = tagAttr(match[3], "color")
+		case unsupportedNode:
+			node.attr = name
+		}
+		top.children = append(top.children, node)
+		stack = append(stack, node)
+	}
+
+	flush()
+	return root.children
+}
+
+func advancedTagKind(name string) formatKind {
+	if kind, ok := advancedTags[name]; ok {
+		return kind
+	}
+	return unsupportedNode
+}
+
+// simpleMarkers are the delimiters the kinds are written with in the simple format
+var simpleMarkers = map[formatKind]string{
+	boldNode:   "*",
+	italicNode: "_",
+	strikeNode: "~",
+}
+
+// advancedTagNames are the tags the kinds are written with in the advanced format
+var advancedTagNames = map[formatKind]string{
+	boldNode:      "b",
+	italicNode:    "i",
+	strikeNode:    "strike",
+	underlineNode: "u",
+}
+
+var advancedEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+var entityPattern = regexp.MustCompile(`^&(?:[a-zA-Z][a-zA-Z0-9]*|#[0-9]+|#[xX][0-9a-fA-F]+);`)
+
+// escapeAdvancedText escapes text for the advanced format. Entities are kept, so escaped values are not escaped twice.
+func escapeAdvancedText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '&' && entityPattern.MatchString(s[i:]) {
+			b.WriteByte('&')
+		} else {
+			b.WriteString(advancedEscaper.Replace(s[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+// preformattedColor is the color preformatted text is shown in, as cards have no monospace font
+const preformattedColor = "#5f6368"
+
+// quotePrefix prefixes the lines of quotes
+const quotePrefix = "> "
+
+// writeAdvancedPreformatted writes preformatted lines, keeping their indentation
+func writeAdvancedPreformatted(b *strings.Builder, text string) {
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			b.WriteString("<br>")
+		}
+		trimmed := strings.TrimLeft(line, " ")
+		indent := strings.Repeat("&nbsp;", len(line)-len(trimmed))
+		b.WriteString(`<font color="` + preformattedColor + `">` + indent + advancedEscaper.Replace(trimmed) + "</font>")
+	}
+}
+
+// writeAdvanced writes the nodes in the advanced format
+func writeAdvanced(b *strings.Builder, nodes []*formatNode, dropped *droppedFormats) {
+	for _, node := range nodes {
+		switch node.kind {
+		case textNode, literalNode:
+			b.WriteString(escapeAdvancedText(node.text))
+		case rawNode:
+			b.WriteString(node.text)
+		case lineBreakNode:
+			b.WriteString("<br>")
+		case codeNode:
+			// Cards have no code formatting, the code is kept as text
+			dropped.add("code")
+			b.WriteString(advancedEscaper.Replace("`" + node.text + "`"))
+		case codeBlockNode:
+			dropped.add("code block")
+			b.WriteString(advancedEscaper.Replace("```" + node.text + "```"))
+		case preformattedNode:
+			writeAdvancedPreformatted(b, node.text)
+		case quoteNode:
+			var quote strings.Builder
+			writeAdvanced(&quote, node.children, dropped)
+			prefix := advancedEscaper.Replace(quotePrefix)
+			b.WriteString(prefix + strings.Replace(quote.String(), "<br>", "<br>"+prefix, -1))
+		case linkNode:
+			b.WriteString(`<a href="` + strings.Replace(node.attr, `"`, "&quot;", -1) + `">`)
+			writeAdvanced(b, node.children, dropped)
+			b.WriteString("</a>")
+		case colorNode:
+			b.WriteString(`<font color="` + strings.Replace(node.attr, `"`, "&quot;", -1) + `">`)
+			writeAdvanced(b, node.children, dropped)
+			b.WriteString("</font>")
+		case unsupportedNode:
+			dropped.add(node.attr)
+			writeAdvanced(b, node.children, dropped)
+		default:
+			tag := advancedTagNames[node.kind]
+			b.WriteString("<" + tag + ">")
+			writeAdvanced(b, node.children, dropped)
+			b.WriteString("</" + tag + ">")
+		}
+	}
+}
+
+// writeSimple writes the nodes in the simple format
+func writeSimple(b *strings.Builder, nodes []*formatNode, dropped *droppedFormats) {
+	for _, node := range nodes {
+		switch node.kind {
+		case textNode, rawNode:
+			b.WriteString(node.text)
+		case literalNode:
+			b.WriteString(string(zeroWidthSpace) + node.text + string(zeroWidthSpace))
+		case lineBreakNode:
+			b.WriteString("\n")
+		case codeNode:
+			b.WriteString("`" + node.text + "`")
+		case codeBlockNode:
+			b.WriteString("```" + node.text + "```")
+		case preformattedNode:
+			if node.attr == "block" {
+				b.WriteString("```" + node.text + "```")
+			} else {
+				b.WriteString("`" + node.text + "`")
+			}
+		case quoteNode:
+			var quote strings.Builder
+			writeSimple(&quote, node.children, dropped)
+			b.WriteString(quotePrefix + strings.Replace(quote.String(), "\n", "\n"+quotePrefix, -1))
+		case linkNode:
+			var label strings.Builder
+			writeSimple(&label, node.children, dropped)
+			switch {
+			case node.attr == "":
+				b.WriteString(label.String())
+			case label.Len() == 0 || label.String() == node.attr:
+				b.WriteString("<" + node.attr + ">")
+			default:
+				b.WriteString("<" + node.attr + "|" + label.String() + ">")
+			}
+		case underlineNode:
+			dropped.add("underline")
+			writeSimple(b, node.children, dropped)
+		case colorNode:
+			dropped.add("font color")
+			writeSimple(b, node.children, dropped)
+		case unsupportedNode:
+			dropped.add(node.attr)
+			writeSimple(b, node.children, dropped)
+		default:
+			var content strings.Builder
+			writeSimple(&content, node.children, dropped)
+			if content.Len() > 0 {
+				marker := simpleMarkers[node.kind]
+				b.WriteString(marker + content.String() + marker)
+			}
+		}
+	}
+}
+
+// supportedTags are the tags of the advanced format Chat renders
+var supportedTags = map[string]bool{
+	"b":      true,
+	"i":      true,
+	"u":      true,
+	"strike": true,
+	"font":   true,
+	"a":      true,
+	"br":     true,
+}
+
+// CheckAdvancedFormatting returns the problems with the tags in text in the advanced format, e.g. unsupported tags like <T>
+// in a commit message or tags which are not closed. Chat renders text with such tags broken or not at all.
+func CheckAdvancedFormatting(text string) []string {
+	var problems []string
+	var open []string
+
+	for i := 0; i < len(text); i++ {
+		match := tagPattern.FindStringSubmatch(text[i:])
+		if text[i] != '<' || match == nil {
+			continue
+		}
+		i += len(match[0]) - 1
+		name := strings.ToLower(match[2])
+
+		switch {
+		case !supportedTags[name]:
+			problems = append(problems, fmt.Sprintf("unsupported tag %s", match[0]))
+		case name == "br":
+		case match[1] == "":
+			open = append(open, name)
+		default:
+			j := len(open) - 1
+			for j >= 0 && open[j] != name {
+				j--
+			}
+			if j < 0 {
+				problems = append(problems, fmt.Sprintf("%s has no opening tag", match[0]))
+				continue
+			}
+			for _, unclosed := range open[j+1:] {
+				problems = append(problems, fmt.Sprintf("<%s> is not closed before %s", unclosed, match[0]))
+			}
+			open = open[:j]
+		}
+	}
+
+	for _, unclosed := range open {
+		problems = append(problems, fmt.Sprintf("<%s> is not closed", unclosed))
+	}
+	return problems
+}
+
+// ConvertSimpleToAdvanced converts text in the simple format of messages to the advanced format of cards.
+// Spans can be nested, but don't continue over a newline. Code is kept as text, as cards don't support it.
+// Supported tags of the advanced format are kept as is, other text is escaped.
+// It returns the formatting which had to be dropped, e.g. code.
+func ConvertSimpleToAdvanced(simple string) (string, []string) {
+	var b strings.Builder
+	var dropped droppedFormats
+	writeAdvanced(&b, parseSimple(simple), &dropped)
+	return b.String(), dropped
+}
+
+// ConvertAdvancedToSimple converts text in the advanced format of cards to the simple format of messages.
+// It returns the formatting which had to be dropped, e.g. underline or font color.
+func ConvertAdvancedToSimple(advanced string) (string, []string) {
+	var b strings.Builder
+	var dropped droppedFormats
+	writeSimple(&b, parseAdvanced(advanced), &dropped)
+	return b.String(), dropped
+}
+
+// SimpleToAdvancedFormatting converts google chats simple formatting to the advanced formatting (as far as possible). Code strings and code blocks are not stripped.
+func SimpleToAdvancedFormatting(simple string) string {
+	formatted, _ := ConvertSimpleToAdvanced(simple)
+	return formatted
+}
+
+// AdvancedToSimpleFormatting converts google chats advanced formatting to the simple formatting (as far as possible)
+func AdvancedToSimpleFormatting(advanced string) string {
+	formatted, _ := ConvertAdvancedToSimple(advanced)
+	return formatted
+}
@@ -0,0 +1,331 @@
+package googlechat
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_SimpleToAdvancedFormatting(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{
+			name:   "Replace at start of string",
+			input:  "*test test* test",
+			output: "<b>test test</b> test",
+		},
+		{
+			name:   "Replace in middle of string",
+			input:  "test *test* test",
+			output: "test <b>test</b> test",
+		},
+		{
+			name:   "Replace at end of string",
+			input:  "test *test test*",
+			output: "test <b>test test</b>",
+		},
+		{
+			name:   "Replace around whole string",
+			input:  "*test test test*",
+			output: "<b>test test test</b>",
+		},
+		{
+			name:   "Replace multiple",
+			input:  "*test* test *test*",
+			output: "<b>test</b> test <b>test</b>",
+		},
+		{
+			name:   "Replace other format",
+			input:  "_test test test_",
+			output: "<i>test test test</i>",
+		},
+		{
+			name:   "Different formats",
+			input:  "*test* _test_ ~test~\n<https://example.org|test>",
+			output: "<b>test</b> <i>test</i> <strike>test</strike>\n<a href=\"https://example.org\">test</a>",
+		},
+		{
+			name:   "Ignore backtics",
+			input:  "*test* _test_ `test` ~test~\n```test```",
+			output: "<b>test</b> <i>test</i> `test` <strike>test</strike>\n```test```",
+		},
+		{
+			name:   "No formatting in code",
+			input:  "`*test* _test_` ```\n*test*\n```",
+			output: "`*test* _test_` ```\n*test*\n```",
+		},
+		{
+			name:   "Nested formats",
+			input:  "*bold _italic ~strike~_*",
+			output: "<b>bold <i>italic <strike>strike</strike></i></b>",
+		},
+		{
+			name:   "Formats in a link",
+			input:  "<https://example.org|*test* _test_>",
+			output: "<a href=\"https://example.org\"><b>test</b> <i>test</i></a>",
+		},
+		{
+			name:   "Mixed formats",
+			input:  "_*test* test ~test_ <https://example.org|test>~",
+			output: "<i><b>test</b> test ~test</i> <a href=\"https://example.org\">test</a>~",
+		},
+		{
+			name:   "Mixed formats, not working over a newline",
+			input:  "_*test* test ~test_\n<https://example.org|test>~",
+			output: "<i><b>test</b> test ~test</i>\n<a href=\"https://example.org\">test</a>~",
+		},
+		{
+			name:   "Asterisks in urls",
+			input:  "*see https://example.org/*/test* <https://example.org/*|*test*>",
+			output: "<b>see https://example.org/*/test</b> <a href=\"https://example.org/*\"><b>test</b></a>",
+		},
+		{
+			name:   "Delimiters in words",
+			input:  "snake_case_name 2*3*4 *test*s",
+			output: "snake_case_name 2*3*4 *test*s",
+		},
+		{
+			name:   "Unpaired delimiters",
+			input:  "* test * _test ~ ** __",
+			output: "* test * _test ~ ** __",
+		},
+		{
+			name:   "Literal delimiters",
+			input:  "\u200b*\u200btest\u200b*\u200b \u200b_\u200btest\u200b_\u200b",
+			output: "\u200b*\u200btest\u200b*\u200b \u200b_\u200btest\u200b_\u200b",
+		},
+		{
+			name:   "Replace nothing with reverse format",
+			input:  "<b>test test test</b>",
+			output: "<b>test test test</b>",
+		},
+		{
+			name:   "Keep advanced formatting",
+			input:  "Build <font color=\"#00ff00\">*passed*</font> a<br>b <a href=\"https://example.org\">_test_</a> <U>test</U>",
+			output: "Build <font color=\"#00ff00\"><b>passed</b></font> a<br>b <a href=\"https://example.org\"><i>test</i></a> <U>test</U>",
+		},
+		{
+			name:   "Escape html",
+			input:  "*a < b & c* <span>test</span>",
+			output: "<b>a &lt; b &amp; c</b> &lt;span&gt;test&lt;/span&gt;",
+		},
+		{
+			name:   "Keep entities",
+			input:  "a &lt; b &amp;&amp; c &#60; d &x",
+			output: "a &lt; b &amp;&amp; c &#60; d &amp;x",
+		},
+		{
+			name:   "Keep mentions and links",
+			input:  "<users/123> <https://example.org>",
+			output: "<users/123> <a href=\"https://example.org\">https://example.org</a>",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			formatted := SimpleToAdvancedFormatting(tc.input)
+
+			if formatted != tc.output {
+				t.Errorf("Substitution failed.\nExpected: %s\nActual: %s", tc.output, formatted)
+			}
+		})
+	}
+}
+
+func Test_AdvancedToSimpleFormatting(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{
+			name:   "Replace at start of string",
+			input:  "<b>test test</b> test",
+			output: "*test test* test",
+		},
+		{
+			name:   "Replace in middle of string",
+			input:  "test <b>test</b> test",
+			output: "test *test* test",
+		},
+		{
+			name:   "Replace at end of string",
+			input:  "test <b>test test</b>",
+			output: "test *test test*",
+		},
+		{
+			name:   "Replace around whole string",
+			input:  "<b>test test test</b>",
+			output: "*test test test*",
+		},
+		{
+			name:   "Replace multiple",
+			input:  "<b>test</b> test <b>test</b>",
+			output: "*test* test *test*",
+		},
+		{
+			name:   "Replace other format",
+			input:  "<i>test test test</i>",
+			output: "_test test test_",
+		},
+		{
+			name:   "Replace nothing with reverse format",
+			input:  "*test test test*",
+			output: "*test test test*",
+		},
+		{
+			name:   "Different formats",
+			input:  "<b>test</b> <i>test</i> <strike>test</strike>\n<a href=\"https://example.org\">test</a>",
+			output: "*test* _test_ ~test~\n<https://example.org|test>",
+		},
+		{
+			name:   "Nested formats",
+			input:  "<B>bold <i>italic <strike>strike</strike></i></B>",
+			output: "*bold _italic ~strike~_*",
+		},
+		{
+			name:   "Mixed formats",
+			input:  "<i><b>test</b> test <strike>test</i> <a href=\"https://example.org\">test</a></strike>",
+			output: "_*test* test ~test~_ <https://example.org|test>",
+		},
+		{
+			name:   "Mixed formats over a newline",
+			input:  "<i><b>test</b> test <strike>test</i>\n<a href=\"https://example.org\">test</a></strike>",
+			output: "_*test* test ~test~_\n<https://example.org|test>",
+		},
+		{
+			name:   "Replace line breaks",
+			input:  "<i><b>test</b> test</i><br><a href=\"https://example.org\">test</a><br/>test",
+			output: "_*test* test_\n<https://example.org|test>\ntest",
+		},
+		{
+			name:   "Replace line breaks in mixed formats",
+			input:  "<i><b>test</b> test <strike>test</i><br><a href=\"https://example.org\">test</a></strike>",
+			output: "_*test* test ~test~_\n<https://example.org|test>",
+		},
+		{
+			name:   "Unclosed tags",
+			input:  "<b>test <i>test",
+			output: "*test _test_*",
+		},
+		{
+			name:   "Unescape html",
+			input:  "<b>a &lt; b &amp; c</b> a < b",
+			output: "*a < b & c* a < b",
+		},
+		{
+			name:   "Link attributes",
+			input:  "<a target=_blank href='https://example.org/?a=1&amp;b=2'>test</a> <a href=\"https://example.org\">https://example.org</a>",
+			output: "<https://example.org/?a=1&b=2|test> <https://example.org>",
+		},
+		{
+			name:   "Strip underline",
+			input:  "<b>test</b> <i>test</i> <strike>test</strike> <u>test</u>",
+			output: "*test* _test_ ~test~ test",
+		},
+		{
+			name:   "Strip font color",
+			input:  "<b>test</b> <i>test</i> <strike>test</strike> <font color=\"green\">test</font>",
+			output: "*test* _test_ ~test~ test",
+		},
+		{
+			name:   "Strip empty formats",
+			input:  "<b></b>test<i> </i>",
+			output: "test_ _",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			formatted := AdvancedToSimpleFormatting(tc.input)
+
+			if formatted != tc.output {
+				t.Errorf("Substitution failed.\nExpected: %s\nActual: %s", tc.output, formatted)
+			}
+		})
+	}
+}
+
+func Test_droppedFormatting(t *testing.T) {
+	tests := []struct {
+		name    string
+		convert func(string) (string, []string)
+		input   string
+		dropped []string
+	}{
+		{
+			name:    "Nothing dropped",
+			convert: ConvertSimpleToAdvanced,
+			input:   "*test* _test_ ~test~",
+		},
+		{
+			name:    "Code",
+			convert: ConvertSimpleToAdvanced,
+			input:   "`test` ```test``` `test`",
+			dropped: []string{"code", "code block"},
+		},
+		{
+			name:    "Underline and font color",
+			convert: ConvertAdvancedToSimple,
+			input:   "<u>test</u> <font color=\"red\">test</font> <u>test</u>",
+			dropped: []string{"underline", "font color"},
+		},
+		{
+			name:    "Unknown tags",
+			convert: ConvertAdvancedToSimple,
+			input:   "<span>test</span>",
+			dropped: []string{"span"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, dropped := tc.convert(tc.input)
+
+			if !cmp.Equal(dropped, tc.dropped) {
+				t.Errorf("Dropped formatting is not correct: %s", cmp.Diff(tc.dropped, dropped))
+			}
+		})
+	}
+}
+
+func Test_CheckAdvancedFormatting(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		problems []string
+	}{
+		{
+			name:  "Supported tags",
+			input: "<b>test</b> <i>test <u>test</u></i><br><font color=\"red\">test</font> <a href=\"https://example.org\">test</a> <br/> a < b &lt;T&gt;",
+		},
+		{
+			name:     "Unsupported tags",
+			input:    "Fix <T> generic <span>test</span>",
+			problems: []string{"unsupported tag <T>", "unsupported tag <span>", "unsupported tag </span>"},
+		},
+		{
+			name:     "Unclosed tags",
+			input:    "<b>test <i>test",
+			problems: []string{"<b> is not closed", "<i> is not closed"},
+		},
+		{
+			name:     "Unbalanced tags",
+			input:    "<b>test <i>test</b></i> test</u>",
+			problems: []string{"<i> is not closed before </b>", "</i> has no opening tag", "</u> has no opening tag"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			problems := CheckAdvancedFormatting(tc.input)
+
+			if !cmp.Equal(problems, tc.problems) {
+				t.Errorf("Returned problems are not correct: %s", cmp.Diff(tc.problems, problems))
+			}
+		})
+	}
+}
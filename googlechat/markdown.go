@@ -0,0 +1,459 @@
+package googlechat
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	fencePattern         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	headingPattern       = regexp.MustCompile(`^ {0,3}#{1,6}(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	thematicBreakPattern = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	quotePattern         = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	listItemPattern      = regexp.MustCompile(`^([ \t]*)([-*+]|\d{1,9}[.)])(?:[ \t]+(.*))?$`)
+	taskPattern          = regexp.MustCompile(`^\[([ xX])\][ \t]+`)
+	tableRowPattern      = regexp.MustCompile(`^ {0,3}\|`)
+	tableDelimiterRow    = regexp.MustCompile(`^ {0,3}\|?(?:\s*:?-+:?\s*\|)+\s*(?::?-+:?\s*)?$`)
+	commentPattern       = regexp.MustCompile(`^<!--[\s\S]*?-->`)
+)
+
+const (
+	// escapable are the characters a backslash escapes
+	escapable = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+	// thematicBreak replaces a horizontal rule
+	thematicBreak = "———"
+	// listIndent indents nested list items
+	listIndent = "    "
+)
+
+// isBlockStart returns true if the line starts a block which interrupts a paragraph
+func isBlockStart(line string) bool {
+	return fencePattern.MatchString(line) || headingPattern.MatchString(line) || thematicBreakPattern.MatchString(line) ||
+		quotePattern.MatchString(line) || listItemPattern.MatchString(line) || tableRowPattern.MatchString(line)
+}
+
+// markdownParser parses GitHub Markdown into the format tree. Blocks are separated by line breaks,
+// constructs without an equivalent degrade, e.g. lists into bullets and code blocks into preformatted lines.
+type markdownParser struct {
+	dropped *droppedFormats
+}
+
+// parseBlocks parses the blocks of the markdown
+func (p *markdownParser) parseBlocks(markdown string) []*formatNode {
+	lines := strings.Split(strings.Replace(markdown, "\r\n", "\n", -1), "\n")
+
+	var nodes []*formatNode
+	blank := false
+	emit := func(block ...*formatNode) {
+		if len(nodes) > 0 {
+			nodes = append(nodes, &formatNode{kind: lineBreakNode})
+			if blank {
+				nodes = append(nodes, &formatNode{kind: lineBreakNode})
+			}
+		}
+		nodes = append(nodes, block...)
+		blank = false
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if strings.TrimSpace(line) == "" {
+			blank = len(nodes) > 0
+			continue
+		}
+
+		if match := fencePattern.FindStringSubmatch(line); match != nil {
+			var code []string
+			for i++; i < len(lines); i++ {
+				if fence := fencePattern.FindStringSubmatch(lines[i]); fence != nil && fence[1][0] == match[1][0] &&
+					len(fence[1]) >= len(match[1]) && strings.TrimSpace(lines[i]) == fence[1] {
+					break
+				}
+				code = append(code, lines[i])
+			}
+			emit(&formatNode{kind: preformattedNode, attr: "block", text: strings.Join(code, "\n")})
+			continue
+		}
+
+		if thematicBreakPattern.MatchString(line) {
+			emit(&formatNode{kind: textNode, text: thematicBreak})
+			continue
+		}
+
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			emit(&formatNode{kind: boldNode, children: p.parseInline(match[1])})
+			continue
+		}
+
+		if quotePattern.MatchString(line) {
+			var quote []string
+			for ; i < len(lines); i++ {
+				match := quotePattern.FindStringSubmatch(lines[i])
+				if match == nil {
+					break
+				}
+				quote = append(quote, match[1])
+			}
+			i--
+			emit(&formatNode{kind: quoteNode, children: p.parseBlocks(strings.Join(quote, "\n"))})
+			continue
+		}
+
+		if tableRowPattern.MatchString(line) {
+			header := true
+			for ; i < len(lines) && tableRowPattern.MatchString(lines[i]); i++ {
+				if tableDelimiterRow.MatchString(lines[i]) {
+					continue
+				}
+				row := p.parseTableRow(lines[i])
+				if header {
+					row = []*formatNode{{kind: boldNode, children: row}}
+					header = false
+				}
+				emit(row...)
+			}
+			i--
+			continue
+		}
+
+		if match := listItemPattern.FindStringSubmatch(line); match != nil {
+			var item []string
+			item, i = p.collectLines(lines, i, match[3])
+			emit(p.parseListItem(match[1], match[2], strings.Join(item, "\n"))...)
+			continue
+		}
+
+		var paragraph []string
+		paragraph, i = p.collectLines(lines, i, line)
+		emit(p.parseInline(strings.Join(paragraph, "\n"))...)
+	}
+
+	return nodes
+}
+
+// collectLines collects the first line and the lines continuing it, until a blank line or a new block,
+// and returns them with the index of the last line
+func (p *markdownParser) collectLines(lines []string, i int, first string) ([]string, int) {
+	collected := []string{first}
+	for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" && !isBlockStart(lines[i+1]) {
+		i++
+		collected = append(collected, lines[i])
+	}
+	return collected, i
+}
+
+// parseListItem parses a list item into a line with its bullet, indented by its level
+func (p *markdownParser) parseListItem(indent, marker, content string) []*formatNode {
+	level := len(strings.Replace(indent, "\t", "    ", -1)) / 2
+
+	bullet := marker + " "
+	if !unicode.IsDigit(rune(marker[0])) {
+		bullet = "• "
+		if match := taskPattern.FindStringSubmatch(content); match != nil {
+			bullet = "☐ "
+			if match[1] != " " {
+				bullet = "☑ "
+			}
+			content = content[len(match[0]):]
+		}
+	}
+
+	return append([]*formatNode{{kind: textNode, text: strings.Repeat(listIndent, level) + bullet}}, p.parseInline(content)...)
+}
+
+// parseTableRow parses a row of a table into its cells, separated by bars
+func (p *markdownParser) parseTableRow(line string) []*formatNode {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+
+	var row []*formatNode
+	for i, cell := range strings.Split(line, "|") {
+		if i > 0 {
+			row = append(row, &formatNode{kind: textNode, text: " | "})
+		}
+		row = append(row, p.parseInline(strings.TrimSpace(cell))...)
+	}
+	return row
+}
+
+// parseInline parses the inline formatting of the lines of a block. Lines are joined by a space,
+// unless they end with two spaces or a backslash for a hard line break.
+func (p *markdownParser) parseInline(text string) []*formatNode {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines[:len(lines)-1] {
+		if strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\") {
+			lines[i] = strings.TrimRight(strings.TrimSuffix(line, "\\"), " ") + "\n"
+		} else {
+			lines[i] = strings.TrimSpace(line) + " "
+		}
+	}
+	lines[len(lines)-1] = strings.TrimSpace(lines[len(lines)-1])
+
+	inline := &inlineParser{s: []rune(strings.TrimSpace(strings.Join(lines, ""))), dropped: p.dropped}
+	nodes, _, _ := inline.parse(0, len(inline.s), "", nil)
+	return nodes
+}
+
+// inlineParser parses the inline markdown of a block: **bold**, *italic*, ~~strike~~, `code`, [links](url), images and html
+type inlineParser struct {
+	s       []rune
+	dropped *droppedFormats
+}
+
+// delimiterKinds map the emphasis delimiters to their kind
+var delimiterKinds = map[string]formatKind{
+	"**": boldNode,
+	"__": boldNode,
+	"*":  italicNode,
+	"_":  italicNode,
+	"~~": strikeNode,
+	"~":  strikeNode,
+}
+
+func (p *inlineParser) hasPrefix(i int, prefix string) bool {
+	return strings.HasPrefix(string(p.s[i:]), prefix)
+}
+
+// run returns the number of times the rune at i repeats
+func (p *inlineParser) run(i int) int {
+	n := 1
+	for i+n < len(p.s) && p.s[i+n] == p.s[i] {
+		n++
+	}
+	return n
+}
+
+// opens returns true if the delimiter at i can open emphasis: it is followed by text, and an underscore is not inside a word
+func (p *inlineParser) opens(i, end int, delimiter string) bool {
+	next := i + len([]rune(delimiter))
+	return next < end && !unicode.IsSpace(p.s[next]) && (delimiter[0] != '_' || i == 0 || !isWordRune(p.s[i-1]))
+}
+
+// closes returns true if the delimiter at i closes emphasis: it follows text, and an underscore is not inside a word
+func (p *inlineParser) closes(i int, delimiter string) bool {
+	next := i + len([]rune(delimiter))
+	return p.hasPrefix(i, delimiter) && i > 0 && !unicode.IsSpace(p.s[i-1]) &&
+		(delimiter[0] != '_' || next == len(p.s) || !isWordRune(p.s[next]))
+}
+
+// link parses a link or image starting with the [ at i, returning its label, url and the end of the link
+func (p *inlineParser) link(i, end int) (label [2]int, url string, next int, ok bool) {
+	depth := 0
+	j := i
+	for ; j < end; j++ {
+		if p.s[j] == '\\' {
+			j++
+			continue
+		}
+		if p.s[j] == '[' {
+			depth++
+		} else if p.s[j] == ']' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	if j+1 >= end || p.s[j+1] != '(' {
+		return
+	}
+
+	k := j + 2
+	for k < end && p.s[k] != ')' {
+		k++
+	}
+	if k >= end {
+		return
+	}
+
+	// The destination may be followed by a title, e.g. [text](https://example.org "Title")
+	destination := strings.Fields(string(p.s[j+2 : k]))
+	if len(destination) == 0 {
+		return
+	}
+	url = strings.TrimSuffix(strings.TrimPrefix(destination[0], "<"), ">")
+
+	return [2]int{i + 1, j}, url, k + 1, true
+}
+
+// parse parses the text from pos until end. If closer is set, it parses emphasis and stops at its closing delimiter.
+// enclosing are the delimiters of the emphasis around it, which are never closed from within.
+func (p *inlineParser) parse(pos, end int, closer string, enclosing []string) ([]*formatNode, int, bool) {
+	var nodes []*formatNode
+	var text []rune
+
+	flush := func() {
+		if len(text) > 0 {
+			nodes = append(nodes, &formatNode{kind: textNode, text: html.UnescapeString(string(text))})
+			text = nil
+		}
+	}
+	add := func(node *formatNode) {
+		flush()
+		nodes = append(nodes, node)
+	}
+
+	for i := pos; i < end; {
+		c := p.s[i]
+
+		switch {
+		case c == '\\' && i+1 < end && (simpleDelimiters[p.s[i+1]] != textNode || p.s[i+1] == '`'):
+			// An escaped delimiter is kept literal in the simple format too
+			add(&formatNode{kind: literalNode, text: string(p.s[i+1])})
+			i += 2
+			continue
+
+		case c == '\\' && i+1 < end && strings.ContainsRune(escapable, p.s[i+1]):
+			text = append(text, p.s[i+1])
+			i += 2
+			continue
+
+		case c == '\n':
+			add(&formatNode{kind: lineBreakNode})
+			i++
+			continue
+
+		case c == '`':
+			// A code span ends at a run of as many backticks
+			n := p.run(i)
+			j := i + n
+			for j < end && (p.s[j] != '`' || p.run(j) != n) {
+				j++
+			}
+			if j >= end {
+				text = append(text, p.s[i:i+n]...)
+				i += n
+				continue
+			}
+			code := string(p.s[i+n : j])
+			if len(code) > 2 && strings.HasPrefix(code, " ") && strings.HasSuffix(code, " ") {
+				code = code[1 : len(code)-1]
+			}
+			add(&formatNode{kind: preformattedNode, text: code})
+			i = j + n
+			continue
+
+		case c == '[' || c == '!' && i+1 < end && p.s[i+1] == '[':
+			start := i
+			if c == '!' {
+				start++
+			}
+			if label, url, next, ok := p.link(start, end); ok {
+				children, _, _ := p.parse(label[0], label[1], "", nil)
+				if len(children) == 0 {
+					children = []*formatNode{{kind: textNode, text: url}}
+				}
+				add(&formatNode{kind: linkNode, attr: url, children: children})
+				i = next
+				continue
+			}
+
+		case c == '<':
+			rest := string(p.s[i:end])
+			if comment := commentPattern.FindString(rest); comment != "" {
+				i += len([]rune(comment))
+				continue
+			}
+			if j := strings.IndexRune(rest, '>'); j > 0 && isLink(rest[1:j]) {
+				url := rest[1:j]
+				add(&formatNode{kind: linkNode, attr: url, children: []*formatNode{{kind: textNode, text: url}}})
+				i += len([]rune(rest[:j+1]))
+				continue
+			}
+			if tag := tagPattern.FindStringSubmatch(rest); tag != nil {
+				if strings.EqualFold(tag[2], "br") {
+					add(&formatNode{kind: lineBreakNode})
+				} else {
+					p.dropped.add("html")
+				}
+				i += len([]rune(tag[0]))
+				continue
+			}
+
+		case (p.hasPrefix(i, "http://") || p.hasPrefix(i, "https://")) && (i == 0 || !isWordRune(p.s[i-1])):
+			// Urls are not formatted, except for the closing delimiter of the emphasis they end
+			j := i
+			for j < end && !unicode.IsSpace(p.s[j]) {
+				j++
+			}
+			for closer != "" && j > i && strings.HasSuffix(string(p.s[i:j]), closer) {
+				j -= len([]rune(closer))
+			}
+			text = append(text, p.s[i:j]...)
+			i = j
+			continue
+
+		case c == '*' || c == '_' || c == '~':
+			if closer != "" && p.closes(i, closer) && (len(nodes) > 0 || len(text) > 0) {
+				flush()
+				return nodes, i + len(closer), true
+			}
+			for _, delimiter := range enclosing {
+				if p.closes(i, delimiter) {
+					return nil, i, false
+				}
+			}
+
+			n := p.run(i)
+			candidates := []string{string(c)}
+			if n >= 2 {
+				candidates = []string{string([]rune{c, c}), string(c)}
+			}
+			outer := enclosing[:len(enclosing):len(enclosing)]
+			if closer != "" {
+				outer = append(outer, closer)
+			}
+			opened := false
+			for _, delimiter := range candidates {
+				if !p.opens(i, end, delimiter) {
+					continue
+				}
+				children, next, ok := p.parse(i+len(delimiter), end, delimiter, outer)
+				if ok {
+					add(&formatNode{kind: delimiterKinds[delimiter], children: children})
+					i = next
+					opened = true
+					break
+				}
+			}
+			if !opened {
+				text = append(text, p.s[i:i+n]...)
+				i += n
+			}
+			continue
+		}
+
+		text = append(text, c)
+		i++
+	}
+
+	if closer != "" {
+		return nil, end, false
+	}
+
+	flush()
+	return nodes, end, true
+}
+
+// ConvertMarkdownToAdvanced converts GitHub Markdown to the advanced format of cards. Headings are bold, lists become bullets,
+// code is shown as preformatted lines and quotes are prefixed. It returns the formatting which had to be dropped, e.g. html.
+func ConvertMarkdownToAdvanced(markdown string) (string, []string) {
+	var b strings.Builder
+	var dropped droppedFormats
+	nodes := (&markdownParser{dropped: &dropped}).parseBlocks(markdown)
+	writeAdvanced(&b, nodes, &dropped)
+	return b.String(), dropped
+}
+
+// ConvertMarkdownToSimple converts GitHub Markdown to the simple format of messages. Headings are bold, lists become bullets
+// and quotes are prefixed. It returns the formatting which had to be dropped, e.g. html.
+func ConvertMarkdownToSimple(markdown string) (string, []string) {
+	var b strings.Builder
+	var dropped droppedFormats
+	nodes := (&markdownParser{dropped: &dropped}).parseBlocks(markdown)
+	writeSimple(&b, nodes, &dropped)
+	return b.String(), dropped
+}
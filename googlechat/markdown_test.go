@@ -0,0 +1,125 @@
+package googlechat
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_ConvertMarkdownToAdvanced(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		output  string
+		dropped []string
+	}{
+		{
+			name:   "Emphasis",
+			input:  "**bold** __bold__ *italic* _italic_ ~~strike~~ ***both***",
+			output: "<b>bold</b> <b>bold</b> <i>italic</i> <i>italic</i> <strike>strike</strike> <b><i>both</i></b>",
+		},
+		{
+			name:   "Nested emphasis",
+			input:  "**bold _italic_ and *italic***",
+			output: "<b>bold <i>italic</i> and <i>italic</i></b>",
+		},
+		{
+			name:   "Delimiters in words and urls",
+			input:  "snake_case_name 2 * 3 https://example.org/a_b_c **https://example.org/*/**",
+			output: "snake_case_name 2 * 3 https://example.org/a_b_c <b>https://example.org/*/</b>",
+		},
+		{
+			name:   "Escaping",
+			input:  `\*not italic\* a < b &amp; c`,
+			output: "*not italic* a &lt; b &amp; c",
+		},
+		{
+			name:   "Links and images",
+			input:  `[the *docs*](https://example.org "Docs") ![logo](https://example.org/logo.png) <https://example.org>`,
+			output: `<a href="https://example.org">the <i>docs</i></a> <a href="https://example.org/logo.png">logo</a> <a href="https://example.org">https://example.org</a>`,
+		},
+		{
+			name:   "Headings and paragraphs",
+			input:  "# Release 1.2 #\nSome text\nwrapped over lines.  \nHard break\n\n## Fixes",
+			output: "<b>Release 1.2</b><br>Some text wrapped over lines.<br>Hard break<br><br><b>Fixes</b>",
+		},
+		{
+			name:   "Lists",
+			input:  "- one\n  continued\n* two\n  - nested\n- [ ] todo\n- [x] done\n\n1. first\n2) second",
+			output: "• one continued<br>• two<br>\u00a0\u00a0\u00a0\u00a0• nested<br>☐ todo<br>☑ done<br><br>1. first<br>2) second",
+		},
+		{
+			name:   "Code",
+			input:  "Run `make <all>`:\n\n```sh\nif true; then\n  make\nfi\n```",
+			output: `Run <font color="#5f6368">make &lt;all&gt;</font>:<br><br><font color="#5f6368">if true; then</font><br><font color="#5f6368">&nbsp;&nbsp;make</font><br><font color="#5f6368">fi</font>`,
+		},
+		{
+			name:   "No formatting in code",
+			input:  "`` *a* `b` ``",
+			output: `<font color="#5f6368">*a* ` + "`b`" + `</font>`,
+		},
+		{
+			name:   "Blockquotes",
+			input:  "> quoted **text**\n> - item\n\nafter",
+			output: "&gt; quoted <b>text</b><br>&gt; • item<br><br>after",
+		},
+		{
+			name:   "Tables and rules",
+			input:  "| Name | Value |\n|---|:---:|\n| a | `1` |\n\n---",
+			output: `<b>Name | Value</b><br>a | <font color="#5f6368">1</font><br><br>———`,
+		},
+		{
+			name:    "Html",
+			input:   "<!-- comment -->Line<br>break <details>hidden</details>",
+			output:  "Line<br>break hidden",
+			dropped: []string{"html"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			formatted, dropped := ConvertMarkdownToAdvanced(tc.input)
+
+			if formatted != tc.output {
+				t.Errorf("Conversion failed.\nExpected: %s\nActual: %s", tc.output, formatted)
+			}
+			if !cmp.Equal(dropped, tc.dropped) {
+				t.Errorf("Dropped formatting is not correct: %s", cmp.Diff(tc.dropped, dropped))
+			}
+		})
+	}
+}
+
+func Test_ConvertMarkdownToSimple(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{
+			name:   "Emphasis",
+			input:  "**bold** *italic* ~~strike~~ [link](https://example.org)",
+			output: "*bold* _italic_ ~strike~ <https://example.org|link>",
+		},
+		{
+			name:   "Release notes",
+			input:  "## 1.2.0\n\n### Fixes\n- Fix `make` on *Linux*\n\n```\ncode\n```\n> note",
+			output: "*1.2.0*\n\n*Fixes*\n• Fix `make` on _Linux_\n\n```code```\n> note",
+		},
+		{
+			name:   "Escaped delimiters",
+			input:  "\\*lit\\* \\_lit\\_ \\~lit\\~ \\`lit\\` \\[lit]",
+			output: "\u200b*\u200blit\u200b*\u200b \u200b_\u200blit\u200b_\u200b \u200b~\u200blit\u200b~\u200b \u200b`\u200blit\u200b`\u200b [lit]",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			formatted, _ := ConvertMarkdownToSimple(tc.input)
+
+			if formatted != tc.output {
+				t.Errorf("Conversion failed.\nExpected: %s\nActual: %s", tc.output, formatted)
+			}
+		})
+	}
+}
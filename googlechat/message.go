@@ -0,0 +1,220 @@
+package googlechat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Message which can be send to Google Chat
+// More info at https://developers.google.com/hangouts/chat/reference/message-formats/cards
+type Message struct {
+	Text  string `json:"text,omitempty"`
+	Cards []Card `json:"cards,omitempty"`
+	// cards in the cardsV2 format, used instead of cards
+	CardsV2 []CardWithID `json:"cardsV2,omitempty"`
+	// thread the message is posted in (optional)
+	Thread *Thread `json:"thread,omitempty"`
+}
+
+// MessageResponse is the message returned by the api after it has been created
+// More info at https://developers.google.com/hangouts/chat/reference/rest/v1/spaces.messages
+type MessageResponse struct {
+	// Resource name of the message, in the form spaces/*/messages/*
+	Name   string  `json:"name,omitempty"`
+	Thread *Thread `json:"thread,omitempty"`
+	Space  *Space  `json:"space,omitempty"`
+	// Warnings about the response of the delivered message, e.g. a body which could not be read or parsed.
+	// The message was delivered, so these are not returned as errors.
+	Warnings []string `json:"-"`
+}
+
+// Space the message was posted in
+type Space struct {
+	// Resource name of the space, in the form spaces/*
+	Name        string `json:"name,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// Card property of a message. can contain a header and must have at least one section
+type Card struct {
+	// header object (optional)
+	Header *Header `json:"header,omitempty"`
+	// sections object. At least one section is required
+	Sections []Section `json:"sections,omitempty"`
+}
+
+// Header property of a card
+type Header struct {
+	Title    string
+	Subtitle string
+	ImageURL string
+	// imageStyle controls the shape of the header image, which may be "square" ("IMAGE") or "circular" ("AVATAR"). The default is "square" ("IMAGE").
+	ImageStyle string
+}
+
+// MarshalJSON implements json.Marshaler.MarshalJSON.
+func (h Header) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	if h.Title != "" {
+		m["title"] = h.Title
+	}
+
+	if h.Subtitle != "" {
+		m["subtitle"] = h.Subtitle
+	}
+
+	if h.ImageURL != "" {
+		m["imageUrl"] = h.ImageURL
+
+		if h.ImageStyle != "" {
+			m["imageStyle"] = h.ImageStyle
+
+			switch h.ImageStyle {
+			case "circular":
+				m["imageStyle"] = "AVATAR"
+			case "square":
+				fallthrough
+			default:
+				m["imageStyle"] = "IMAGE"
+			}
+		}
+	}
+
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.UnmarshalJSON.
+func (h *Header) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Title      string `json:"title"`
+		Subtitle   string `json:"subtitle"`
+		ImageURL   string `json:"imageUrl"`
+		ImageStyle string `json:"imageStyle"`
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+
+	*h = Header{
+		Title:    raw.Title,
+		Subtitle: raw.Subtitle,
+		ImageURL: raw.ImageURL,
+	}
+
+	switch raw.ImageStyle {
+	case "":
+	case "AVATAR":
+		h.ImageStyle = "circular"
+	case "IMAGE":
+		h.ImageStyle = "square"
+	default:
+		return fmt.Errorf("unknown imageStyle %s, expected IMAGE or AVATAR", raw.ImageStyle)
+	}
+
+	return nil
+}
+
+// CreateHeader creates a Header struct if at least one field is present, return nil pointer otherwise
+func CreateHeader(title, subtitle, imageURL, imageStyle string) *Header {
+	if title == "" && subtitle == "" && imageURL == "" && imageStyle == "" {
+		return nil
+	}
+
+	return &Header{
+		Title:      title,
+		Subtitle:   subtitle,
+		ImageURL:   imageURL,
+		ImageStyle: imageStyle,
+	}
+}
+
+// Section of a card. can contain multiple widgets, but at least one is required. Sections are separated by a horizontal line
+type Section struct {
+	// Section header (optional)
+	Header string `json:"header,omitempty"`
+	// widgets object. At least one widget is required.
+	Widgets []*Widget `json:"widgets,omitempty"`
+	// collapsible sections are only supported by cardsV2
+	Collapsible               bool `json:"-"`
+	UncollapsibleWidgetsCount int  `json:"-"`
+}
+
+// Widget of a section. Can contain only one type of UI element
+type Widget struct {
+	TextParagraph *TextParagraph `json:"textParagraph,omitempty"`
+	KeyValue      *KeyValue      `json:"keyValue,omitempty"`
+	Image         *Image         `json:"image,omitempty"`
+	// buttons object can contain one or more buttons. will be laid out horizontally
+	Buttons []*Button `json:"buttons,omitempty"`
+}
+
+// TextParagraph UI element
+type TextParagraph struct {
+	// The text to display inside the paragraph
+	Text string `json:"text,omitempty"`
+}
+
+// KeyValue UI element
+type KeyValue struct {
+	TopLabel         string   `json:"topLabel,omitempty"`
+	Content          string   `json:"content,omitempty"`
+	ContentMultiline string   `json:"contentMultiline,omitempty"`
+	BottomLabel      string   `json:"bottomLabel,omitempty"`
+	OnClick          *OnClick `json:"onClick,omitempty"`
+	// either iconUrl of icon can be used
+	IconURL string `json:"iconUrl,omitempty"`
+	// either iconUrl of icon can be used
+	Icon   string  `json:"icon,omitempty"`
+	Button *Button `json:"button,omitempty"`
+}
+
+// Image UI element
+type Image struct {
+	ImageURL string   `json:"imageUrl,omitempty"`
+	OnClick  *OnClick `json:"onClick,omitempty"`
+	// alt text is only supported by cardsV2
+	AltText string `json:"-"`
+}
+
+// Button UI element. Can contain either a TextButton or an ImageButton
+type Button struct {
+	TextButton  *TextButton  `json:"textButton,omitempty"`
+	ImageButton *ImageButton `json:"imageButton,omitempty"`
+}
+
+// TextButton UI element
+type TextButton struct {
+	Text    string   `json:"text,omitempty"`
+	OnClick *OnClick `json:"onClick,omitempty"`
+}
+
+// ImageButton UI element
+type ImageButton struct {
+	// either iconUrl of icon can be used
+	IconURL string `json:"iconUrl,omitempty"`
+	// either iconUrl of icon can be used
+	Icon    string   `json:"icon,omitempty"`
+	OnClick *OnClick `json:"onClick,omitempty"`
+}
+
+// OnClick handler object
+type OnClick struct {
+	OpenLink *OpenLink `json:"openLink,omitempty"`
+}
+
+// OpenLink object
+type OpenLink struct {
+	URL string `json:"url,omitempty"`
+}
+
+// Thread of a message. Messages with the same thread key are posted in the same thread
+type Thread struct {
+	// Name of the thread, as returned by the api
+	Name string `json:"name,omitempty"`
+	// ThreadKey used to group messages in a thread. Also sent as a query parameter for webhooks
+	ThreadKey string `json:"threadKey,omitempty"`
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"gopkg.in/yaml.v2"
+)
+
+// ParseKeyValues parses a simpler KeyValue json array to the KeyValue object used by the api
+func ParseKeyValues(raw string) (widgets []*googlechat.Widget, err error) {
+	// ignore empty string
+	if raw == "" {
+		return
+	}
+
+	var keyValueInput []KeyValueInput
+
+	err = json.Unmarshal([]byte(raw), &keyValueInput)
+	if err != nil {
+		return
+	}
+
+	// Throw error for empty array
+	if len(keyValueInput) == 0 {
+		// Ignore an empty array: It is a way to remove the keyValues in case of an error
+		return
+	}
+
+	widgets = []*googlechat.Widget{}
+
+	for _, keyValue := range keyValueInput {
+		var widget *googlechat.Widget
+		widget, err = parseKeyValue(keyValue)
+		if err != nil {
+			return
+		}
+
+		widgets = append(widgets, widget)
+	}
+
+	return
+}
+
+// parseKeyValue converts a KeyValueInput to a KeyValue widget
+func parseKeyValue(keyValue KeyValueInput) (widget *googlechat.Widget, err error) {
+	if keyValue.Content == "" {
+		err = fmt.Errorf("KeyValue Should have content")
+		return
+	}
+
+	// either iconUrl of icon can be used
+	if keyValue.IconURL != "" && keyValue.Icon != "" {
+		err = fmt.Errorf("KeyValue object should have either an iconUrl, an icon, or neither, but not both")
+		return
+	}
+
+	var onClick *googlechat.OnClick
+	if keyValue.OnClick != "" {
+		onClick = &googlechat.OnClick{
+			OpenLink: &googlechat.OpenLink{
+				URL: keyValue.OnClick,
+			},
+		}
+	}
+
+	var button *googlechat.Button
+	if keyValue.Button != nil {
+		button, err = parseButtonInput(*keyValue.Button, "KeyValue button")
+		if err != nil {
+			return
+		}
+	}
+
+	widget = &googlechat.Widget{
+		KeyValue: &googlechat.KeyValue{
+			TopLabel:         keyValue.TopLabel,
+			Content:          keyValue.Content,
+			ContentMultiline: strconv.FormatBool(keyValue.ContentMultiline),
+			BottomLabel:      keyValue.BottomLabel,
+			OnClick:          onClick,
+			IconURL:          keyValue.IconURL,
+			Icon:             keyValue.Icon,
+			Button:           button,
+		},
+	}
+
+	return
+}
+
+// parseButtonInput converts a ButtonInput to a Button. The name is used to refer to the button in errors
+func parseButtonInput(input ButtonInput, name string) (button *googlechat.Button, err error) {
+	if input.OnClick == "" {
+		err = fmt.Errorf("%s should have an onClick value", name)
+		return
+	}
+
+	if (input.Text != "" && (input.IconURL != "" || input.Icon != "")) || (input.IconURL != "" && (input.Text != "" || input.Icon != "")) {
+		err = fmt.Errorf("%s should have either a text, an iconUrl, or an icon field, not multiple", name)
+		return
+	}
+
+	var buttonOnClick = &googlechat.OnClick{
+		OpenLink: &googlechat.OpenLink{
+			URL: input.OnClick,
+		},
+	}
+
+	var textButton *googlechat.TextButton
+	if input.Text != "" {
+		textButton = &googlechat.TextButton{
+			Text:    input.Text,
+			OnClick: buttonOnClick,
+		}
+	}
+
+	var imageButton *googlechat.ImageButton
+	if input.Icon != "" || input.IconURL != "" {
+		imageButton = &googlechat.ImageButton{
+			IconURL: input.IconURL,
+			Icon:    input.Icon,
+			OnClick: buttonOnClick,
+		}
+	}
+
+	if textButton == nil && imageButton == nil {
+		err = fmt.Errorf("%s should have either an iconUrl, an icon or text field", name)
+		return
+	}
+
+	button = &googlechat.Button{
+		TextButton:  textButton,
+		ImageButton: imageButton,
+	}
+
+	return
+}
+
+// parseImages parses images separated by newlines. Each image declaration contains a url,
+// optionally followed by an onClick url and an alt text, separated by pipe characters.
+// Empty lines are omitted. A single - removes the images, e.g. to not show the images of the success state in images_on_error.
+func parseImages(s string) (widgets []*googlechat.Widget, err error) {
+	if strings.TrimSpace(s) == "-" {
+		return
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		split := strings.SplitN(line, "|", 3)
+		for len(split) < 3 {
+			split = append(split, "")
+		}
+		imageURL, onClickURL, altText := strings.TrimSpace(split[0]), strings.TrimSpace(split[1]), strings.TrimSpace(split[2])
+
+		var widget *googlechat.Widget
+		widget, err = parseImage(imageURL, onClickURL, altText)
+		if err != nil {
+			err = fmt.Errorf("Could not parse image with declaration %s, %s", line, err)
+			return
+		}
+
+		widgets = append(widgets, widget)
+	}
+
+	return
+}
+
+// parseImage creates an Image widget, validating its urls
+func parseImage(imageURL, onClickURL, altText string) (*googlechat.Widget, error) {
+	if !isWebURL(imageURL) {
+		return nil, fmt.Errorf("%s is not a valid http(s) url", imageURL)
+	}
+
+	var onClick *googlechat.OnClick
+	if onClickURL != "" {
+		if !isURL(onClickURL) {
+			return nil, fmt.Errorf("%s is not a valid url", onClickURL)
+		}
+
+		onClick = &googlechat.OnClick{
+			OpenLink: &googlechat.OpenLink{
+				URL: onClickURL,
+			},
+		}
+	}
+
+	return &googlechat.Widget{
+		Image: &googlechat.Image{
+			ImageURL: imageURL,
+			OnClick:  onClick,
+			AltText:  altText,
+		},
+	}, nil
+}
+
+// isURL returns true if s is an absolute url, e.g. https://example.org or mailto:user@example.org
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "")
+}
+
+// isWebURL returns true if s is an absolute http or https url
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func parseButtons(s string) (buttons []*googlechat.Button, err error) {
+	var buttonConf [][3]string
+	buttonConf, err = triples(s)
+	if err != nil {
+		return
+	}
+
+	for _, triple := range buttonConf {
+		var button *googlechat.Button
+
+		button, err = parseButton(triple)
+		if err != nil {
+			return
+		}
+
+		buttons = append(buttons, button)
+	}
+
+	return
+}
+
+func parseButton(triple [3]string) (button *googlechat.Button, err error) {
+	onClick := &googlechat.OnClick{
+		OpenLink: &googlechat.OpenLink{
+			URL: triple[2],
+		},
+	}
+
+	if triple[0] == "text" {
+		button = &googlechat.Button{
+			TextButton: &googlechat.TextButton{
+				Text:    triple[1],
+				OnClick: onClick,
+			},
+		}
+
+	} else if triple[0] == "builtin-icon" {
+		button = &googlechat.Button{
+			ImageButton: &googlechat.ImageButton{
+				Icon:    triple[1],
+				OnClick: onClick,
+			},
+		}
+
+	} else if triple[0] == "custom-icon" {
+		button = &googlechat.Button{
+			ImageButton: &googlechat.ImageButton{
+				IconURL: triple[1],
+				OnClick: onClick,
+			},
+		}
+
+	} else {
+		err = fmt.Errorf("Unknown button type %s", triple[0])
+	}
+
+	return
+}
+
+// pairs slices every lines in s into two substrings separated by the first pipe
+// character and returns a slice of those pairs.
+func triples(s string) (ps [][3]string, err error) {
+	s = strings.TrimSpace(s)
+
+	for _, line := range strings.Split(s, "\n") {
+		var tripple [3]string
+
+		tripple, err = splitTriple(line)
+
+		if err != nil {
+			return
+		}
+
+		ps = append(ps, tripple)
+	}
+	return
+}
+
+func splitTriple(s string) (triple [3]string, err error) {
+	splitString := strings.SplitN(s, "|", 3)
+
+	if len(splitString) == 3 && splitString[0] != "" && splitString[1] != "" && splitString[2] != "" {
+		triple = [3]string{splitString[0], splitString[1], splitString[2]}
+
+	} else {
+		err = fmt.Errorf("Could not parse button with declaration %s", s)
+	}
+
+	return
+}
+
+// ButtonInput defines the input format of the Button objects
+type ButtonInput struct {
+	Text    string `json:"text,omitempty"`
+	IconURL string `json:"iconUrl,omitempty"` // either iconUrl of icon can be used
+	Icon    string `json:"icon,omitempty"`    // either iconUrl of icon can be used
+	OnClick string `json:"onClick,omitempty"`
+}
+
+// KeyValueInput defines the input format of the KeyValue object
+type KeyValueInput struct {
+	TopLabel         string       `json:"topLabel,omitempty"`
+	Content          string       `json:"content,omitempty"`
+	ContentMultiline bool         `json:"contentMultiline,omitempty"`
+	BottomLabel      string       `json:"bottomLabel,omitempty"`
+	OnClick          string       `json:"onClick,omitempty"`
+	IconURL          string       `json:"iconUrl,omitempty"` // either iconUrl of icon can be used
+	Icon             string       `json:"icon,omitempty"`    // either iconUrl of icon can be used
+	Button           *ButtonInput `json:"button,omitempty"`
+}
+
+// unmarshalJSONOrYAML parses an input written either as JSON or as YAML into v, using the json tags of v.
+// YAML is converted to JSON first, so both formats are decoded the same way.
+func unmarshalJSONOrYAML(raw string, v interface{}) error {
+	trimmed := strings.TrimSpace(raw)
+	if strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		err := json.Unmarshal([]byte(raw), v)
+		if _, ok := err.(*json.SyntaxError); !ok {
+			return err
+		}
+
+		// Flow style YAML, e.g. [{header: Status}], is not valid JSON
+		b, yamlErr := yamlToJSON(raw)
+		if yamlErr != nil {
+			return err
+		}
+		return json.Unmarshal(b, v)
+	}
+
+	b, err := yamlToJSON(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// yamlToJSON converts a YAML document to JSON
+func yamlToJSON(raw string) ([]byte, error) {
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(raw), &value); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonValue(value))
+}
+
+// jsonValue converts the maps decoded from YAML, which can have keys of any type, to maps with string keys
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonValue(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
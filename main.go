@@ -1,13 +1,15 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"html"
 	"os"
+	"strings"
 
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
 	"github.com/bitrise-io/go-steputils/stepconf"
 	"github.com/bitrise-io/go-utils/log"
 )
@@ -17,75 +19,231 @@ type Config struct {
 	Debug bool `env:"is_debug_mode,opt[yes,no]"`
 
 	// Message
-	WebhookURL        stepconf.Secret `env:"webhook_url"`
-	Message           string          `env:"message"`
-	MessageOnError    string          `env:"message_on_error"`
-	Title             string          `env:"title"`
-	TitleOnError      string          `env:"title_on_error"`
-	Subtitle          string          `env:"subtitle"`
-	SubtitleOnError   string          `env:"subtitle_on_error"`
-	ImageURL          string          `env:"image"`
-	ImageURLOnError   string          `env:"image_on_error"`
-	ImageStyle        string          `env:"image_style,opt[square,circular]"`
-	ImageStyleOnError string          `env:"image_style_on_error,opt[square,circular]"`
-	Text              string          `env:"text"`
-	TextOnError       string          `env:"text_on_error"`
-	KeyValue          string          `env:"key_value"`
-	KeyValueOnError   string          `env:"key_value_on_error"`
-	Buttons           string          `env:"buttons"`
-	ButtonsOnError    string          `env:"buttons_on_error"`
+	WebhookURL          stepconf.Secret `env:"webhook_url"`
+	Message             string          `env:"message"`
+	MessageOnError      string          `env:"message_on_error"`
+	Title               string          `env:"title"`
+	TitleOnError        string          `env:"title_on_error"`
+	Subtitle            string          `env:"subtitle"`
+	SubtitleOnError     string          `env:"subtitle_on_error"`
+	ImageURL            string          `env:"image"`
+	ImageURLOnError     string          `env:"image_on_error"`
+	ImageStyle          string          `env:"image_style,opt[square,circular]"`
+	ImageStyleOnError   string          `env:"image_style_on_error,opt[square,circular]"`
+	Text                string          `env:"text"`
+	TextOnError         string          `env:"text_on_error"`
+	KeyValue            string          `env:"key_value"`
+	KeyValueOnError     string          `env:"key_value_on_error"`
+	Images              string          `env:"images"`
+	ImagesOnError       string          `env:"images_on_error"`
+	Buttons             string          `env:"buttons"`
+	ButtonsOnError      string          `env:"buttons_on_error"`
+	Sections            string          `env:"sections"`
+	Cards               string          `env:"cards"`
+	CardsJSON           string          `env:"cards_json"`
+	CardsJSONOnError    string          `env:"cards_json_on_error"`
+	CardsFile           string          `env:"cards_file"`
+	TemplateFile        string          `env:"template_file"`
+	TemplatePartialsDir string          `env:"template_partials_dir"`
+	Preset              string          `env:"preset,opt[none,build-report]"`
+
+	// Build states
+	MessageOnFixed         string `env:"message_on_fixed"`
+	MessageOnStillFailing  string `env:"message_on_still_failing"`
+	MessageOnAborted       string `env:"message_on_aborted"`
+	TitleOnFixed           string `env:"title_on_fixed"`
+	TitleOnStillFailing    string `env:"title_on_still_failing"`
+	TitleOnAborted         string `env:"title_on_aborted"`
+	ImageURLOnFixed        string `env:"image_on_fixed"`
+	ImageURLOnStillFailing string `env:"image_on_still_failing"`
+	ImageURLOnAborted      string `env:"image_on_aborted"`
+	ButtonsOnFixed         string `env:"buttons_on_fixed"`
+	ButtonsOnStillFailing  string `env:"buttons_on_still_failing"`
+	ButtonsOnAborted       string `env:"buttons_on_aborted"`
+	Overrides              string `env:"overrides"`
+	NotifyOn               string `env:"notify_on"`
+	StateFile              string `env:"state_file"`
+	CacheDir               string `env:"BITRISE_CACHE_DIR"`
+	PreviousBuildStatus    string `env:"previous_build_status"`
+
+	CardVersion string `env:"card_version,opt[v1,v2]"`
+	ButtonColor string `env:"button_color"`
+
+	// Threading
+	ThreadKey         string `env:"thread_key"`
+	ThreadReplyOption string `env:"thread_reply_option,opt[fallback_to_new_thread,reply_or_fail]"`
+
+	// Chat REST API
+	Transport         string          `env:"transport,opt[webhook,api]"`
+	ServiceAccountKey stepconf.Secret `env:"service_account_key"`
+	TokenURL          string          `env:"token_url"`
+	APIBaseURL        string          `env:"api_base_url"`
+	APIAction         string          `env:"api_action,opt[create,update,delete]"`
+	Space             string          `env:"space"`
+	MessageName       string          `env:"message_name"`
 
 	ConvertSimpleToAvancedFormat bool `env:"convert_simple_to_advanced_format,opt[yes,no]"`
 	ConvertAvancedToSimpleFormat bool `env:"convert_advanced_to_simple_format,opt[yes,no]"`
+	Markdown                     bool `env:"markdown,opt[yes,no]"`
+	EscapeValues                 bool `env:"escape_values,opt[yes,no]"`
+
+	// Delivery
+	RetryMaxAttempts  int     `env:"retry_max_attempts"`
+	RetryBaseDelay    float64 `env:"retry_base_delay"`
+	RetryJitter       bool    `env:"retry_jitter,opt[yes,no]"`
+	RetryMaxTime      float64 `env:"retry_max_time"`
+	Concurrency       int     `env:"concurrency"`
+	FailOnTargetError string  `env:"fail_on_target_error,opt[any,all,never]"`
+	DryRun            bool    `env:"dry_run,opt[yes,no]"`
+	DeployDir         string  `env:"BITRISE_DEPLOY_DIR"`
+	SpoolDir          string  `env:"spool_dir"`
+	Mode              string  `env:"mode,opt[send,resend]"`
+	FailurePolicy     string  `env:"failure_policy,opt[fail,warn,ignore]"`
+	BuildStatus       string  `env:"build_status"`
+
+	// Send rules
+	SendWhen          string `env:"send_when,opt[always,failure,status_change,not_success]"`
+	BranchInclude     string `env:"branch_include"`
+	BranchExclude     string `env:"branch_exclude"`
+	TriggerFilter     string `env:"trigger_filter,opt[any,pull_request,push,tag]"`
+	WorkflowAllowlist string `env:"workflow_allowlist"`
 }
 
-// success is true if the build is successful, false otherwise.
-var success = os.Getenv("BITRISE_BUILD_STATUS") == "0"
-
-func selectValue(ifSuccess, ifFailed string) string {
-	if success || ifFailed == "" {
+// selectValue returns the value for the status of the build, falling back to ifSuccess if ifFailed is empty
+func selectValue(build BuildContext, ifSuccess, ifFailed string) string {
+	if build.Succeeded() || ifFailed == "" {
 		return ifSuccess
 	}
 	return ifFailed
 }
 
-func selectAvancedFormatValue(ifSuccess, ifFailed string, simpleToAvancedFormat bool) string {
-	selected := selectValue(ifSuccess, ifFailed)
-
+// advancedFormatValue converts the value from simple to advanced formatting if enabled
+func advancedFormatValue(value string, simpleToAvancedFormat bool) string {
 	if simpleToAvancedFormat {
-		selected = SimpleToAdvancedFormatting(selected)
+		formatted, dropped := googlechat.ConvertSimpleToAdvanced(value)
+		logDroppedFormatting("advanced", dropped)
+		return formatted
 	}
+	return value
+}
 
-	return selected
+// simpleFormatValue converts the value from advanced to simple formatting if enabled
+func simpleFormatValue(value string, advancedToSimpleFormat bool) string {
+	if advancedToSimpleFormat {
+		formatted, dropped := googlechat.ConvertAdvancedToSimple(value)
+		logDroppedFormatting("simple", dropped)
+		return formatted
+	}
+	return value
 }
 
-func selectSimpleFormatValue(ifSuccess, ifFailed string, advancedToSimpleFormat bool) string {
-	selected := selectValue(ifSuccess, ifFailed)
+// cardTextValue formats the value for the cards, converting it from markdown or the simple format if enabled
+func cardTextValue(c Config, value string) string {
+	if c.Markdown {
+		formatted, dropped := googlechat.ConvertMarkdownToAdvanced(value)
+		logDroppedFormatting("advanced", dropped)
+		return formatted
+	}
+	return advancedFormatValue(value, c.ConvertSimpleToAvancedFormat)
+}
 
-	if advancedToSimpleFormat {
-		selected = AdvancedToSimpleFormatting(selected)
+// messageTextValue formats the value for the message text, converting it from markdown or the advanced format if enabled
+func messageTextValue(c Config, value string) string {
+	if c.Markdown {
+		formatted, dropped := googlechat.ConvertMarkdownToSimple(value)
+		logDroppedFormatting("simple", dropped)
+		return formatted
+	}
+	return simpleFormatValue(value, c.ConvertAvancedToSimpleFormat)
+}
+
+// logDroppedFormatting warns about the formatting the conversion to the format had to drop
+func logDroppedFormatting(format string, dropped []string) {
+	if len(dropped) > 0 {
+		log.Warnf("Formatting not supported by the %s format was dropped: %s", format, strings.Join(dropped, ", "))
+	}
+}
+
+// newMessage builds the message from the inputs for the build
+func newMessage(c Config, build BuildContext) (msg googlechat.Message, err error) {
+	state := build.State
+	if state == "" {
+		state = resolveState(build.Status, "")
+	}
+	c, err = resolveConfig(c, state)
+	if err != nil {
+		return
+	}
+	if c.EscapeValues {
+		c = escapeSubstitutedValues(c, build, os.Getenv)
+	}
+	c, err = renderInputs(c, build)
+	if err != nil {
+		return
+	}
+
+	// The preset is applied to the rendered inputs, so values like the commit message are not executed as a template
+	build.State = state
+	c, err = applyPreset(c, build)
+	if err != nil {
+		return
+	}
+
+	if c.TemplateFile != "" {
+		msg, err = renderMessageTemplate(c.TemplateFile, c.TemplatePartialsDir, build, c.EscapeValues)
+	} else {
+		msg, err = newInputsMessage(c, build)
+	}
+	if err != nil {
+		return
+	}
+
+	threadKey, err := renderThreadKey(c.ThreadKey, build)
+	if err != nil {
+		return
+	}
+	if threadKey != "" {
+		msg.Thread = &googlechat.Thread{
+			ThreadKey: threadKey,
+		}
+	}
+
+	warnCardTexts(msg)
+
+	if c.CardVersion == cardsV2 && len(msg.Cards) > 0 {
+		var color *googlechat.Color
+		color, err = googlechat.ParseColor(c.ButtonColor)
+		if err != nil {
+			return
+		}
+
+		msg.CardsV2 = googlechat.ToCardsV2(msg.Cards, googlechat.CardV2Options{
+			ButtonColor: color,
+		})
+		msg.Cards = nil
 	}
 
-	return selected
+	return
 }
 
-func newMessage(c Config) (msg Message, err error) {
-	sections := []Section{}
+// newInputsMessage builds the text and the cards of the message from the inputs
+func newInputsMessage(c Config, build BuildContext) (msg googlechat.Message, err error) {
+	sections := []googlechat.Section{}
 
-	text := selectAvancedFormatValue(c.Text, c.TextOnError, c.ConvertSimpleToAvancedFormat)
+	text := cardTextValue(c, c.Text)
 	if text != "" {
-		sections = append(sections, Section{
-			Widgets: []*Widget{{
-				TextParagraph: &TextParagraph{
+		sections = append(sections, googlechat.Section{
+			Widgets: []*googlechat.Widget{{
+				TextParagraph: &googlechat.TextParagraph{
 					Text: text,
 				},
 			}},
 		})
 	}
 
-	keyValueConfig := selectValue(c.KeyValue, c.KeyValueOnError)
+	keyValueConfig := c.KeyValue
 	if keyValueConfig != "" {
-		var keyValueWidgets []*Widget
+		var keyValueWidgets []*googlechat.Widget
 		keyValueWidgets, err = ParseKeyValues(keyValueConfig)
 
 		if err != nil {
@@ -93,126 +251,309 @@ func newMessage(c Config) (msg Message, err error) {
 		}
 
 		if keyValueWidgets != nil {
-			sections = append(sections, Section{
+			sections = append(sections, googlechat.Section{
 				Widgets: keyValueWidgets,
 			})
 		}
 	}
 
-	buttonConfig := selectValue(c.Buttons, c.ButtonsOnError)
+	imageConfig := c.Images
+	if imageConfig != "" {
+		var imageWidgets []*googlechat.Widget
+		imageWidgets, err = parseImages(imageConfig)
+
+		if err != nil {
+			return
+		}
+
+		if imageWidgets != nil {
+			sections = append(sections, googlechat.Section{
+				Widgets: imageWidgets,
+			})
+		}
+	}
+
+	buttonConfig := c.Buttons
 	if buttonConfig != "" {
-		var buttons []*Button
+		var buttons []*googlechat.Button
 		buttons, err = parseButtons(buttonConfig)
 
 		if err != nil {
 			return
 		}
 
-		sections = append(sections, Section{
-			Widgets: []*Widget{{
+		sections = append(sections, googlechat.Section{
+			Widgets: []*googlechat.Widget{{
 				Buttons: buttons,
 			}},
 		})
 	}
 
-	message := selectSimpleFormatValue(c.Message, c.MessageOnError, c.ConvertAvancedToSimpleFormat)
+	var declaredSections []googlechat.Section
+	declaredSections, err = ParseSections(c.Sections, build)
+	if err != nil {
+		return
+	}
+	sections = append(sections, declaredSections...)
+
+	message := messageTextValue(c, c.Message)
 	if message == "" {
-		message = selectSimpleFormatValue(c.Title, c.TitleOnError, c.ConvertAvancedToSimpleFormat)
+		message = messageTextValue(c, c.Title)
 	}
 	if message == "" {
-		message = selectSimpleFormatValue(c.Text, c.TextOnError, c.ConvertAvancedToSimpleFormat)
-	}
-
-	msg = Message{
-		Text: message,
-		Cards: []Card{{
-			Header: CreateHeader(
-				selectAvancedFormatValue(c.Title, c.TitleOnError, c.ConvertSimpleToAvancedFormat),
-				selectAvancedFormatValue(c.Subtitle, c.SubtitleOnError, c.ConvertSimpleToAvancedFormat),
-				selectValue(c.ImageURL, c.ImageURLOnError),
-				selectValue(c.ImageStyle, c.ImageStyleOnError),
-			),
+		text := c.Text
+		if c.EscapeValues && !c.Markdown && !c.ConvertAvancedToSimpleFormat {
+			// The values are escaped for the cards, the message text shows them as written
+			text = html.UnescapeString(text)
+		}
+		message = messageTextValue(c, text)
+	}
+
+	header := googlechat.CreateHeader(
+		cardTextValue(c, c.Title),
+		cardTextValue(c, c.Subtitle),
+		c.ImageURL,
+		c.ImageStyle,
+	)
+
+	var cards []googlechat.Card
+	cards, err = ParseCards(c.Cards, build)
+	if err != nil {
+		return
+	}
+
+	// The single card inputs define the first card, which is left out if only the cards input has content
+	if len(sections) > 0 || len(cards) == 0 {
+		cards = append([]googlechat.Card{{
+			Header:   header,
 			Sections: sections,
-		}},
+		}}, cards...)
+	}
+
+	msg = googlechat.Message{
+		Text:  message,
+		Cards: cards,
+	}
+
+	// Raw cards replace the card layout built from the inputs
+	var rawCardsJSON string
+	rawCardsJSON, err = readRawCards(c.CardsJSON, c.CardsFile)
+	if err != nil {
+		return
+	}
+	if rawCardsJSON != "" {
+		msg.Cards, msg.CardsV2, err = ParseRawCards(rawCardsJSON, c.CardVersion)
+		if err != nil {
+			return
+		}
+		applyRawCardsHeader(&msg, header)
 	}
 
 	return
 }
 
-// postMessage sends a message to a channel.
-func postMessage(conf Config, msg Message) error {
+// postMessage sends a message to every configured webhook, retrying temporary failures as configured.
+// It returns the response of the first webhook the message was sent to.
+func postMessage(conf Config, msg googlechat.Message) (*googlechat.MessageResponse, error) {
 	b, err := json.Marshal(msg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	log.Debugf("Request to Google Chat: %s\n", b)
 
-	url := string(conf.WebhookURL)
+	targets, err := parseTargets(string(conf.WebhookURL))
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+	var threadKey string
+	if msg.Thread != nil {
+		threadKey = msg.Thread.ThreadKey
+	}
 
-	client := &http.Client{}
+	policy := newRetryPolicy(conf)
+	results := sendToTargets(targets, conf.Concurrency, func(target Target) (*googlechat.MessageResponse, error) {
+		client := newWebhookClient(target.URL, conf.ThreadReplyOption)
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send the request: %s", err)
-	}
-	defer func() {
-		if cerr := resp.Body.Close(); err == nil {
-			err = cerr
+		var resp *googlechat.MessageResponse
+		err := withRetry(target.Label, policy, func() (err error) {
+			resp, err = client.SendPayload(context.Background(), b, threadKey)
+			return
+		})
+		if resp != nil {
+			warnResponse(target.Label, resp.Warnings)
 		}
-	}()
+		return resp, err
+	})
 
-	if resp.StatusCode != http.StatusOK {
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("server error: %s, failed to read response: %s", resp.Status, err)
-		}
-		return fmt.Errorf("server error: %s, response: %s", resp.Status, body)
+	printResults(results)
+	spoolFailures(conf, msg, b, results)
+
+	return firstResponse(results), checkResults(results, conf.FailOnTargetError)
+}
+
+// newWebhookClient creates a client posting to the webhook url with the messageReplyOption of the thread_reply_option
+func newWebhookClient(webhookURL, replyOption string) *googlechat.Client {
+	client := googlechat.NewClient(webhookURL)
+	client.ReplyOption = replyOptionValue(replyOption)
+	return client
+}
+
+// warnResponse warns about the problems with the response of a delivered message, label names the target or the message
+func warnResponse(label string, warnings []string) {
+	for _, warning := range warnings {
+		log.Warnf("%s: the message was sent, but %s", label, warning)
 	}
+}
 
-	return nil
+// exportResponse exports the names of the created message, its thread and space as step outputs
+func exportResponse(resp *googlechat.MessageResponse) {
+	if resp == nil {
+		return
+	}
+
+	outputs := map[string]string{
+		messageNameOutput: resp.Name,
+	}
+	if resp.Thread != nil {
+		outputs[threadNameOutput] = resp.Thread.Name
+	}
+	if resp.Space != nil {
+		outputs[spaceNameOutput] = resp.Space.Name
+	}
+
+	for _, key := range []string{messageNameOutput, threadNameOutput, spaceNameOutput} {
+		if value := outputs[key]; value != "" {
+			if err := exportOutput(key, value); err != nil {
+				log.Warnf("Warning: %s", err)
+			}
+		}
+	}
 }
 
 func validate(conf *Config) error {
-	if conf.WebhookURL == "" {
-		return fmt.Errorf("WebhookURL is empty. You need to provide one")
+	if conf.Transport == apiTransport {
+		if err := validateAPIConfig(conf); err != nil {
+			return err
+		}
+
+		// Deleting a message doesn't need any content
+		if conf.APIAction == deleteAction {
+			return nil
+		}
+	} else {
+		if conf.WebhookURL == "" {
+			return fmt.Errorf("WebhookURL is empty. You need to provide one")
+		}
+
+		if _, err := parseTargets(string(conf.WebhookURL)); err != nil {
+			return err
+		}
+	}
+
+	if conf.Text == "" && conf.Buttons == "" && conf.KeyValue == "" && conf.Images == "" && conf.Sections == "" && conf.Cards == "" && conf.CardsJSON == "" && conf.CardsFile == "" && conf.TemplateFile == "" && conf.Preset != buildReportPreset {
+		return fmt.Errorf("Text, keyValue, images, buttons, sections, cards, template_file and preset are empty. You need to provide at least one")
 	}
 
-	if conf.Text == "" && conf.Buttons == "" && conf.KeyValue == "" {
-		return fmt.Errorf("Text, keyValue and buttons are empty. You need to provide at least one")
+	if _, err := sendRules(*conf); err != nil {
+		return err
+	}
+
+	if _, err := parseOverrides(conf.Overrides); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func main() {
+// run executes the phases of the step and returns its outcome. Errors are returned as a PhaseError.
+// args are the command line arguments which are not flags.
+func run(args []string) (string, error) {
 	var conf Config
 	if err := stepconf.Parse(&conf); err != nil {
-		log.Errorf("Error: %s\n", err)
-		os.Exit(1)
+		return "", &PhaseError{Phase: parsePhase, Err: err}
 	}
 	stepconf.Print(conf)
 	log.SetEnableDebugLog(conf.Debug)
 
+	if conf.Mode == resendMode || (len(args) > 0 && args[0] == resendMode) {
+		if err := resendSpool(conf); err != nil {
+			return "", &PhaseError{Phase: deliverPhase, Err: err}
+		}
+		return sentOutcome, nil
+	}
+
 	if err := validate(&conf); err != nil {
-		log.Errorf("Error: %s\n", err)
-		os.Exit(1)
+		return "", &PhaseError{Phase: validatePhase, Err: err}
 	}
 
-	msg, err := newMessage(conf)
+	build, err := readBuildContext(conf, os.Getenv)
 	if err != nil {
-		log.Errorf("Error: %s", err)
-		os.Exit(1)
+		return "", &PhaseError{Phase: validatePhase, Err: err}
+	}
+	resolveBuildState(&build, newPreviousBuildLookup(conf))
+	log.Debugf("Build context: %+v", build)
+
+	// The state is recorded even if no message is sent, so the next build is compared to this one
+	if !conf.DryRun {
+		defer recordBuildState(conf, build)
 	}
 
-	if err := postMessage(conf, msg); err != nil {
-		log.Errorf("Error: %s", err)
-		os.Exit(1)
+	rules, _ := sendRules(conf)
+	if input, reason := checkSendRules(rules, build); input != "" {
+		log.Printf("Google Chat message suppressed by %s: %s", input, reason)
+		return suppressedOutcome, nil
+	}
+
+	msg, err := newMessage(conf, build)
+	if err != nil {
+		return "", &PhaseError{Phase: renderPhase, Err: err}
+	}
+
+	if conf.DryRun {
+		if err := dryRun(conf, msg); err != nil {
+			return "", &PhaseError{Phase: renderPhase, Err: err}
+		}
+		return dryRunOutcome, nil
+	}
+
+	var resp *googlechat.MessageResponse
+	if conf.Transport == apiTransport {
+		resp, err = callAPI(conf, msg)
+	} else {
+		resp, err = postMessage(conf, msg)
+	}
+	if err != nil {
+		return "", &PhaseError{Phase: deliverPhase, Err: err}
+	}
+
+	exportResponse(resp)
+
+	if msg.Thread != nil {
+		if err := exportOutput(threadKeyOutput, msg.Thread.ThreadKey); err != nil {
+			log.Warnf("Warning: %s", err)
+		}
 	}
 
 	log.Donef("\nGoogle Chat message successfully sent! 🚀\n")
 
-	os.Exit(0)
+	return sentOutcome, nil
+}
+
+func main() {
+	args, err := applyConfigSources(os.Args[1:], os.Getenv, os.Setenv)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+
+	// The failure policy is read directly, as it should also apply if the other inputs can't be parsed
+	policy := os.Getenv("failure_policy")
+
+	if err != nil {
+		os.Exit(finish(policy, "", &PhaseError{Phase: parsePhase, Err: err}))
+	}
+
+	outcome, err := run(args)
+
+	os.Exit(finish(policy, outcome, err))
 }
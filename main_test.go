@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -56,9 +57,9 @@ func Test_selectValue(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			success = tc.success
+			build := testBuildContext(tc.success)
 
-			selected := selectValue(tc.ifSuccess, tc.ifFailed)
+			selected := selectValue(build, tc.ifSuccess, tc.ifFailed)
 
 			if tc.output != selected {
 				t.Errorf("Returned string is not correct: expected %+v, got %+v", tc.output, selected)
@@ -67,94 +68,52 @@ func Test_selectValue(t *testing.T) {
 	}
 }
 
-func Test_selectAvancedFormatValue(t *testing.T) {
+func Test_advancedFormatValue(t *testing.T) {
 	tests := []struct {
 		name      string
-		success   bool
-		ifSuccess string
-		ifFailed  string
+		value     string
 		transform bool
 		output    string
 	}{
 		{
-			name:      "Success without transforming message",
-			success:   true,
-			ifSuccess: "*Successfull*",
-			ifFailed:  "*Failed*",
+			name:      "Without transforming message",
+			value:     "*Successfull*",
 			transform: false,
 			output:    "*Successfull*",
 		}, {
-			name:      "Success with transforming message",
-			success:   true,
-			ifSuccess: "*Successfull*",
-			ifFailed:  "*Failed*",
+			name:      "With transforming message",
+			value:     "*Successfull*",
 			transform: true,
 			output:    "<b>Successfull</b>",
-		}, {
-			name:      "Failed without transforming message",
-			success:   false,
-			ifSuccess: "*Successfull*",
-			ifFailed:  "*Failed*",
-			transform: false,
-			output:    "*Failed*",
-		}, {
-			name:      "Failed with transforming message",
-			success:   false,
-			ifSuccess: "*Successfull*",
-			ifFailed:  "*Failed*",
-			transform: true,
-			output:    "<b>Failed</b>",
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			success = tc.success
-
-			selected := selectAvancedFormatValue(tc.ifSuccess, tc.ifFailed, tc.transform)
+			formatted := advancedFormatValue(tc.value, tc.transform)
 
-			if tc.output != selected {
-				t.Errorf("Returned string is not correct: expected %+v, got %+v", tc.output, selected)
+			if tc.output != formatted {
+				t.Errorf("Returned string is not correct: expected %+v, got %+v", tc.output, formatted)
 			}
 		})
 	}
 }
 
-func Test_selectSimpleFormatValue(t *testing.T) {
+func Test_simpleFormatValue(t *testing.T) {
 	tests := []struct {
 		name      string
-		success   bool
-		ifSuccess string
-		ifFailed  string
+		value     string
 		transform bool
 		output    string
 	}{
 		{
-			name:      "Success without transforming message",
-			success:   true,
-			ifSuccess: "<b>Successfull</b>",
-			ifFailed:  "<b>Failed</b>",
-			transform: false,
-			output:    "<b>Successfull</b>",
-		}, {
-			name:      "Success with transforming message",
-			success:   true,
-			ifSuccess: "<b>Successfull</b>",
-			ifFailed:  "<b>Failed</b>",
-			transform: true,
-			output:    "*Successfull*",
-		}, {
-			name:      "Failed without transforming message",
-			success:   false,
-			ifSuccess: "<b>Successfull</b>",
-			ifFailed:  "<b>Failed</b>",
+			name:      "Without transforming message",
+			value:     "<b>Failed</b>",
 			transform: false,
 			output:    "<b>Failed</b>",
 		}, {
-			name:      "Failed with transforming message",
-			success:   false,
-			ifSuccess: "<b>Successfull</b>",
-			ifFailed:  "<b>Failed</b>",
+			name:      "With transforming message",
+			value:     "<b>Failed</b>",
 			transform: true,
 			output:    "*Failed*",
 		},
@@ -162,12 +121,10 @@ func Test_selectSimpleFormatValue(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			success = tc.success
-
-			selected := selectSimpleFormatValue(tc.ifSuccess, tc.ifFailed, tc.transform)
+			formatted := simpleFormatValue(tc.value, tc.transform)
 
-			if tc.output != selected {
-				t.Errorf("Returned string is not correct: expected %+v, got %+v", tc.output, selected)
+			if tc.output != formatted {
+				t.Errorf("Returned string is not correct: expected %+v, got %+v", tc.output, formatted)
 			}
 		})
 	}
@@ -177,7 +134,7 @@ func Test_newMessage(t *testing.T) {
 	tests := []struct {
 		name   string
 		config Config
-		output Message
+		output googlechat.Message
 		err    string
 	}{
 		{
@@ -192,38 +149,38 @@ func Test_newMessage(t *testing.T) {
 				KeyValue:   `[{"topLabel":"top label","content":"content\non <i>multiple<\/i> lines","contentMultiline":true,"bottomLabel":"bottom label","onClick":"https://example.org","button":{"text":"button text","onClick":"http://example.org"}},{"content":"<b>content<\/b> :)","iconUrl":"https://example.com","button":{"iconUrl":"http://example.com","onClick":"http://example.org"}},{"content":"content.","icon":"CLOCK","button":{"icon":"MULTIPLE_PEOPLE","onClick":"http://example.org"}}]`,
 				Buttons:    "text|example.org website|https://example.org",
 			},
-			output: Message{
+			output: googlechat.Message{
 				Text: "title",
-				Cards: []Card{{
-					Header: &Header{
+				Cards: []googlechat.Card{{
+					Header: &googlechat.Header{
 						Title:      "title",
 						Subtitle:   "subtitle",
 						ImageURL:   "image url",
 						ImageStyle: "image style",
 					},
-					Sections: []Section{{
-						Widgets: []*Widget{{
-							TextParagraph: &TextParagraph{
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{
+							TextParagraph: &googlechat.TextParagraph{
 								Text: "text",
 							},
 						}},
 					}, {
-						Widgets: []*Widget{{
-							KeyValue: &KeyValue{
+						Widgets: []*googlechat.Widget{{
+							KeyValue: &googlechat.KeyValue{
 								TopLabel:         "top label",
 								Content:          "content\non <i>multiple</i> lines",
 								ContentMultiline: "true",
 								BottomLabel:      "bottom label",
-								OnClick: &OnClick{
-									OpenLink: &OpenLink{
+								OnClick: &googlechat.OnClick{
+									OpenLink: &googlechat.OpenLink{
 										URL: "https://example.org",
 									},
 								},
-								Button: &Button{
-									TextButton: &TextButton{
+								Button: &googlechat.Button{
+									TextButton: &googlechat.TextButton{
 										Text: "button text",
-										OnClick: &OnClick{
-											OpenLink: &OpenLink{
+										OnClick: &googlechat.OnClick{
+											OpenLink: &googlechat.OpenLink{
 												URL: "http://example.org",
 											},
 										},
@@ -231,15 +188,15 @@ func Test_newMessage(t *testing.T) {
 								},
 							},
 						}, {
-							KeyValue: &KeyValue{
+							KeyValue: &googlechat.KeyValue{
 								Content:          "<b>content</b> :)",
 								ContentMultiline: "false",
 								IconURL:          "https://example.com",
-								Button: &Button{
-									ImageButton: &ImageButton{
+								Button: &googlechat.Button{
+									ImageButton: &googlechat.ImageButton{
 										IconURL: "http://example.com",
-										OnClick: &OnClick{
-											OpenLink: &OpenLink{
+										OnClick: &googlechat.OnClick{
+											OpenLink: &googlechat.OpenLink{
 												URL: "http://example.org",
 											},
 										},
@@ -247,15 +204,15 @@ func Test_newMessage(t *testing.T) {
 								},
 							},
 						}, {
-							KeyValue: &KeyValue{
+							KeyValue: &googlechat.KeyValue{
 								Content:          "content.",
 								ContentMultiline: "false",
 								Icon:             "CLOCK",
-								Button: &Button{
-									ImageButton: &ImageButton{
+								Button: &googlechat.Button{
+									ImageButton: &googlechat.ImageButton{
 										Icon: "MULTIPLE_PEOPLE",
-										OnClick: &OnClick{
-											OpenLink: &OpenLink{
+										OnClick: &googlechat.OnClick{
+											OpenLink: &googlechat.OpenLink{
 												URL: "http://example.org",
 											},
 										},
@@ -264,12 +221,12 @@ func Test_newMessage(t *testing.T) {
 							},
 						}},
 					}, {
-						Widgets: []*Widget{{
-							Buttons: []*Button{{
-								TextButton: &TextButton{
+						Widgets: []*googlechat.Widget{{
+							Buttons: []*googlechat.Button{{
+								TextButton: &googlechat.TextButton{
 									Text: "example.org website",
-									OnClick: &OnClick{
-										OpenLink: &OpenLink{
+									OnClick: &googlechat.OnClick{
+										OpenLink: &googlechat.OpenLink{
 											URL: "https://example.org",
 										},
 									},
@@ -291,18 +248,18 @@ func Test_newMessage(t *testing.T) {
 				ImageStyle: "image style",
 				Text:       "text",
 			},
-			output: Message{
+			output: googlechat.Message{
 				Text: "title",
-				Cards: []Card{{
-					Header: &Header{
+				Cards: []googlechat.Card{{
+					Header: &googlechat.Header{
 						Title:      "title",
 						Subtitle:   "subtitle",
 						ImageURL:   "image url",
 						ImageStyle: "image style",
 					},
-					Sections: []Section{{
-						Widgets: []*Widget{{
-							TextParagraph: &TextParagraph{
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{
+							TextParagraph: &googlechat.TextParagraph{
 								Text: "text",
 							},
 						}},
@@ -321,22 +278,22 @@ func Test_newMessage(t *testing.T) {
 				ImageStyle: "image style",
 				Buttons:    "text|example.org website|https://example.org",
 			},
-			output: Message{
+			output: googlechat.Message{
 				Text: "title",
-				Cards: []Card{{
-					Header: &Header{
+				Cards: []googlechat.Card{{
+					Header: &googlechat.Header{
 						Title:      "title",
 						Subtitle:   "subtitle",
 						ImageURL:   "image url",
 						ImageStyle: "image style",
 					},
-					Sections: []Section{{
-						Widgets: []*Widget{{
-							Buttons: []*Button{{
-								TextButton: &TextButton{
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{
+							Buttons: []*googlechat.Button{{
+								TextButton: &googlechat.TextButton{
 									Text: "example.org website",
-									OnClick: &OnClick{
-										OpenLink: &OpenLink{
+									OnClick: &googlechat.OnClick{
+										OpenLink: &googlechat.OpenLink{
 											URL: "https://example.org",
 										},
 									},
@@ -354,25 +311,25 @@ func Test_newMessage(t *testing.T) {
 				WebhookURL: "URL",
 				KeyValue:   `[{"topLabel":"top label","content":"content\non <i>multiple<\/i> lines","contentMultiline":true,"bottomLabel":"bottom label","onClick":"https://example.org","button":{"text":"button text","onClick":"http://example.org"}}]`,
 			},
-			output: Message{
-				Cards: []Card{{
-					Sections: []Section{{
-						Widgets: []*Widget{{
-							KeyValue: &KeyValue{
+			output: googlechat.Message{
+				Cards: []googlechat.Card{{
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{
+							KeyValue: &googlechat.KeyValue{
 								TopLabel:         "top label",
 								Content:          "content\non <i>multiple</i> lines",
 								ContentMultiline: "true",
 								BottomLabel:      "bottom label",
-								OnClick: &OnClick{
-									OpenLink: &OpenLink{
+								OnClick: &googlechat.OnClick{
+									OpenLink: &googlechat.OpenLink{
 										URL: "https://example.org",
 									},
 								},
-								Button: &Button{
-									TextButton: &TextButton{
+								Button: &googlechat.Button{
+									TextButton: &googlechat.TextButton{
 										Text: "button text",
-										OnClick: &OnClick{
-											OpenLink: &OpenLink{
+										OnClick: &googlechat.OnClick{
+											OpenLink: &googlechat.OpenLink{
 												URL: "http://example.org",
 											},
 										},
@@ -391,15 +348,15 @@ func Test_newMessage(t *testing.T) {
 				WebhookURL: "URL",
 				Buttons:    "text|example.org website|https://example.org",
 			},
-			output: Message{
-				Cards: []Card{{
-					Sections: []Section{{
-						Widgets: []*Widget{{
-							Buttons: []*Button{{
-								TextButton: &TextButton{
+			output: googlechat.Message{
+				Cards: []googlechat.Card{{
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{
+							Buttons: []*googlechat.Button{{
+								TextButton: &googlechat.TextButton{
 									Text: "example.org website",
-									OnClick: &OnClick{
-										OpenLink: &OpenLink{
+									OnClick: &googlechat.OnClick{
+										OpenLink: &googlechat.OpenLink{
 											URL: "https://example.org",
 										},
 									},
@@ -417,12 +374,12 @@ func Test_newMessage(t *testing.T) {
 				WebhookURL: "URL",
 				Text:       "text",
 			},
-			output: Message{
+			output: googlechat.Message{
 				Text: "text",
-				Cards: []Card{{
-					Sections: []Section{{
-						Widgets: []*Widget{{
-							TextParagraph: &TextParagraph{
+				Cards: []googlechat.Card{{
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{
+							TextParagraph: &googlechat.TextParagraph{
 								Text: "text",
 							},
 						}},
@@ -442,22 +399,22 @@ func Test_newMessage(t *testing.T) {
 				ImageStyle: "image style",
 				Buttons:    "text|example.org website|https://example.org",
 			},
-			output: Message{
+			output: googlechat.Message{
 				Text: "message",
-				Cards: []Card{{
-					Header: &Header{
+				Cards: []googlechat.Card{{
+					Header: &googlechat.Header{
 						Title:      "title",
 						Subtitle:   "subtitle",
 						ImageURL:   "image url",
 						ImageStyle: "image style",
 					},
-					Sections: []Section{{
-						Widgets: []*Widget{{
-							Buttons: []*Button{{
-								TextButton: &TextButton{
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{
+							Buttons: []*googlechat.Button{{
+								TextButton: &googlechat.TextButton{
 									Text: "example.org website",
-									OnClick: &OnClick{
-										OpenLink: &OpenLink{
+									OnClick: &googlechat.OnClick{
+										OpenLink: &googlechat.OpenLink{
 											URL: "https://example.org",
 										},
 									},
@@ -481,22 +438,22 @@ func Test_newMessage(t *testing.T) {
 				Buttons:    "text|example.org website|https://example.org",
 				KeyValue:   "[]",
 			},
-			output: Message{
+			output: googlechat.Message{
 				Text: "message",
-				Cards: []Card{{
-					Header: &Header{
+				Cards: []googlechat.Card{{
+					Header: &googlechat.Header{
 						Title:      "title",
 						Subtitle:   "subtitle",
 						ImageURL:   "image url",
 						ImageStyle: "image style",
 					},
-					Sections: []Section{{
-						Widgets: []*Widget{{
-							Buttons: []*Button{{
-								TextButton: &TextButton{
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{
+							Buttons: []*googlechat.Button{{
+								TextButton: &googlechat.TextButton{
 									Text: "example.org website",
-									OnClick: &OnClick{
-										OpenLink: &OpenLink{
+									OnClick: &googlechat.OnClick{
+										OpenLink: &googlechat.OpenLink{
 											URL: "https://example.org",
 										},
 									},
@@ -508,6 +465,55 @@ func Test_newMessage(t *testing.T) {
 			},
 			err: "",
 		},
+		{
+			name: "Create message with thread key",
+			config: Config{
+				WebhookURL: "URL",
+				Text:       "text",
+				ThreadKey:  "builds",
+			},
+			output: googlechat.Message{
+				Text: "text",
+				Cards: []googlechat.Card{{
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{
+							TextParagraph: &googlechat.TextParagraph{
+								Text: "text",
+							},
+						}},
+					}},
+				}},
+				Thread: &googlechat.Thread{
+					ThreadKey: "builds",
+				},
+			},
+			err: "",
+		},
+		{
+			name: "Create message from markdown",
+			config: Config{
+				WebhookURL:                   "URL",
+				Title:                        "**Release** 1.2",
+				Text:                         "## Fixes\n- Fix `make`",
+				Markdown:                     true,
+				ConvertSimpleToAvancedFormat: true,
+			},
+			output: googlechat.Message{
+				Text: "*Release* 1.2",
+				Cards: []googlechat.Card{{
+					Header: &googlechat.Header{
+						Title: "<b>Release</b> 1.2",
+					},
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{
+							TextParagraph: &googlechat.TextParagraph{
+								Text: `<b>Fixes</b><br>• Fix <font color="#5f6368">make</font>`,
+							},
+						}},
+					}},
+				}},
+			},
+		},
 		{
 			name: "Create message with button error",
 			config: Config{
@@ -519,7 +525,7 @@ func Test_newMessage(t *testing.T) {
 				Text:       "text",
 				Buttons:    "invalid",
 			},
-			output: Message{
+			output: googlechat.Message{
 				Cards: nil,
 			},
 			err: "Could not parse button with declaration invalid",
@@ -527,10 +533,10 @@ func Test_newMessage(t *testing.T) {
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			// Make sure success is set to true, so less config is needed. selectValue is tested in another test
-			success = true
+			// Use a successful build, so less config is needed. selectValue is tested in another test
+			build := testBuildContext(true)
 
-			message, err := newMessage(tc.config)
+			message, err := newMessage(tc.config, build)
 
 			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
 				t.Errorf("Unexpected error: %s", err)
@@ -569,7 +575,7 @@ func Test_validate(t *testing.T) {
 			config: &Config{
 				WebhookURL: "URL",
 			},
-			err: "Text, keyValue and buttons are empty. You need to provide at least one",
+			err: "Text, keyValue, images, buttons, sections, cards, template_file and preset are empty. You need to provide at least one",
 		}, {
 			name: "Text",
 			config: &Config{
@@ -638,3 +644,25 @@ func Test_validate(t *testing.T) {
 		})
 	}
 }
+
+func Test_exportResponse(t *testing.T) {
+	exported := map[string]string{}
+	exportOutput = func(key, value string) error {
+		exported[key] = value
+		return nil
+	}
+	defer func() { exportOutput = exportEnvironmentWithEnvman }()
+
+	exportResponse(&googlechat.MessageResponse{
+		Name:   "spaces/AAA/messages/BBB",
+		Thread: &googlechat.Thread{Name: "spaces/AAA/threads/CCC"},
+	})
+
+	expected := map[string]string{
+		"GOOGLE_CHAT_MESSAGE_NAME": "spaces/AAA/messages/BBB",
+		"GOOGLE_CHAT_THREAD_NAME":  "spaces/AAA/threads/CCC",
+	}
+	if !cmp.Equal(exported, expected) {
+		t.Errorf("Exported outputs are not correct: expected %+v, got %+v", expected, exported)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+)
+
+// partialsPattern matches the partials in the partials directory, included as e.g. {{ template "header.tmpl" . }}
+const partialsPattern = "*.tmpl"
+
+// rawMessage is the message a template file renders to
+type rawMessage struct {
+	Text string `json:"text,omitempty"`
+	rawCards
+}
+
+// loadMessageTemplate parses the template file and the partials in the directory, which defaults to the directory of the template file.
+// Errors report the file and line.
+func loadMessageTemplate(path, partialsDir string) (*template.Template, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %s", err)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template file %s: %s", path, err)
+	}
+
+	if partialsDir == "" {
+		partialsDir = filepath.Dir(path)
+	}
+	partials, err := filepath.Glob(filepath.Join(partialsDir, partialsPattern))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, partial := range partials {
+		if filepath.Clean(partial) == filepath.Clean(path) {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(partial)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read partial: %s", err)
+		}
+
+		if _, err := tmpl.New(filepath.Base(partial)).Parse(string(b)); err != nil {
+			return nil, fmt.Errorf("failed to parse partial %s: %s", partial, err)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// renderMessageTemplate renders the template file with the BuildContext into a message. The template renders the JSON
+// of the message: its text and either cards or cardsV2, which are validated like the cards_json input.
+// If escape is set the output of the actions of the template file and its partials is html escaped, see escapeActions.
+func renderMessageTemplate(path, partialsDir string, build BuildContext, escape bool) (msg googlechat.Message, err error) {
+	tmpl, err := loadMessageTemplate(path, partialsDir)
+	if err != nil {
+		return
+	}
+	if escape {
+		for _, t := range tmpl.Templates() {
+			if t.Tree != nil {
+				escapeActions(t.Tree.Root)
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	if err = tmpl.Execute(&b, build); err != nil {
+		err = fmt.Errorf("failed to render template file %s: %s", path, err)
+		return
+	}
+
+	var parsed rawMessage
+	if err = decodeStrict(b.String(), &parsed); err != nil {
+		err = fmt.Errorf("template file %s rendered invalid JSON%s: %s", path, jsonErrorLine(b.String(), err), err)
+		return
+	}
+
+	if err = parsed.validate(); err != nil {
+		err = fmt.Errorf("template file %s: %s", path, err)
+		return
+	}
+
+	if escape {
+		// The values are escaped for the cards, the message text shows them as written
+		parsed.Text = html.UnescapeString(parsed.Text)
+	}
+
+	msg = googlechat.Message{
+		Text:    parsed.Text,
+		Cards:   parsed.Cards,
+		CardsV2: parsed.CardsV2,
+	}
+	return
+}
+
+// jsonErrorLine returns the line of the rendered JSON a decoding error occurred on and its text, e.g.
+// ` at line 3 of the rendered output ("text": main,)`, if it is known. The line of the rendered output
+// can differ from the line of the template file, so its text is included to find it.
+func jsonErrorLine(rendered string, err error) string {
+	var offset int64
+	switch err := err.(type) {
+	case *json.SyntaxError:
+		offset = err.Offset
+	case *json.UnmarshalTypeError:
+		offset = err.Offset
+	default:
+		return ""
+	}
+
+	if offset > int64(len(rendered)) {
+		offset = int64(len(rendered))
+	}
+	start := strings.LastIndex(rendered[:offset], "\n") + 1
+	end := strings.Index(rendered[offset:], "\n")
+	if end < 0 {
+		end = len(rendered)
+	} else {
+		end += int(offset)
+	}
+	line := strings.Count(rendered[:offset], "\n") + 1
+	return fmt.Sprintf(" at line %d of the rendered output (%s)", line, strings.TrimSpace(rendered[start:end]))
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_renderMessageTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"app/message.tmpl": `{
+  "text": {{ printf "%s on %s" .Status .Branch | json }},
+  "cards": [{
+    {{ template "header.tmpl" . }},
+    "sections": [{"widgets": [{"textParagraph": {"text": {{ .CommitMessage | truncate 20 | json }}}}]}]
+  }]
+}`,
+		"app/header.tmpl":     `"header": {"title": {{ if .Failed }}"Build failed"{{ else }}"Build passed"{{ end }}}`,
+		"shared/header.tmpl":  `"header": {"title": "House style", "subtitle": {{ .CommitShort | json }}}`,
+		"escaped/header.tmpl": `"header": {"title": {{ .CommitMessage | raw | json }}, "subtitle": "{{ .CommitMessage }}"}`,
+		"broken.tmpl":         "{\n  \"text\": {{ .Branch }\n}",
+		"invalid.tmpl":        "{\n  \"text\": \"{{ .Branch }}\",\n  \"cards\": [{\"sections\": []}]\n}",
+		"syntax.tmpl":         "{\n  \"text\": {{ .Branch }}\n}",
+		"unknown.tmpl":        "{\n  \"text\": \"\",\n  \"attachment\": {}\n}",
+		"field.tmpl":          "{\n  \"text\": \"{{ .Unknown }}\"\n}",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %s", name, err)
+		}
+	}
+
+	build := BuildContext{Status: statusFailed, Branch: "main", Commit: "0123456789", CommitMessage: `Fix "quoted" <b>commit</b> message`}
+
+	tests := []struct {
+		name        string
+		file        string
+		partialsDir string
+		escape      bool
+		output      googlechat.Message
+		err         string
+	}{
+		{
+			name: "Partial next to the template",
+			file: "app/message.tmpl",
+			output: googlechat.Message{
+				Text: "failed on main",
+				Cards: []googlechat.Card{{
+					Header: &googlechat.Header{Title: "Build failed"},
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{TextParagraph: &googlechat.TextParagraph{Text: `Fix "quoted" <b>com…`}}},
+					}},
+				}},
+			},
+		},
+		{
+			name:        "Shared partials directory",
+			file:        "app/message.tmpl",
+			partialsDir: "shared",
+			output: googlechat.Message{
+				Text: "failed on main",
+				Cards: []googlechat.Card{{
+					Header: &googlechat.Header{Title: "House style", Subtitle: "0123456"},
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{TextParagraph: &googlechat.TextParagraph{Text: `Fix "quoted" <b>com…`}}},
+					}},
+				}},
+			},
+		},
+		{
+			name:        "Escaped values",
+			file:        "app/message.tmpl",
+			partialsDir: "escaped",
+			escape:      true,
+			output: googlechat.Message{
+				Text: "failed on main",
+				Cards: []googlechat.Card{{
+					Header: &googlechat.Header{Title: `Fix "quoted" <b>commit</b> message`, Subtitle: `Fix &#34;quoted&#34; &lt;b&gt;commit&lt;/b&gt; message`},
+					Sections: []googlechat.Section{{
+						Widgets: []*googlechat.Widget{{TextParagraph: &googlechat.TextParagraph{Text: `Fix &#34;quoted&#34; &lt;b&gt;com…`}}},
+					}},
+				}},
+			},
+		},
+		{
+			name: "Missing file",
+			file: "missing.tmpl",
+			err:  "failed to read template file: open {dir}/missing.tmpl: no such file or directory",
+		},
+		{
+			name:        "Template error",
+			file:        "broken.tmpl",
+			partialsDir: "shared",
+			err:         `failed to parse template file {dir}/broken.tmpl: template: broken.tmpl:2: unexpected "}" in operand`,
+		},
+		{
+			name:        "Partial error",
+			file:        "app/message.tmpl",
+			partialsDir: ".",
+			err:         `failed to parse partial {dir}/broken.tmpl: template: broken.tmpl:2: unexpected "}" in operand`,
+		},
+		{
+			name:        "Render error",
+			file:        "field.tmpl",
+			partialsDir: "shared",
+			err:         `failed to render template file {dir}/field.tmpl: template: field.tmpl:2:14: executing "field.tmpl" at <.Unknown>: can't evaluate field Unknown in type main.BuildContext`,
+		},
+		{
+			name:        "Invalid JSON",
+			file:        "syntax.tmpl",
+			partialsDir: "shared",
+			err:         `template file {dir}/syntax.tmpl rendered invalid JSON at line 2 of the rendered output ("text": main): invalid character 'm' looking for beginning of value`,
+		},
+		{
+			name:        "Unknown field",
+			file:        "unknown.tmpl",
+			partialsDir: "shared",
+			err:         `template file {dir}/unknown.tmpl rendered invalid JSON: json: unknown field "attachment"`,
+		},
+		{
+			name:        "Invalid cards",
+			file:        "invalid.tmpl",
+			partialsDir: "shared",
+			err:         "template file {dir}/invalid.tmpl: Invalid cards:\n- cards[0]: at least one section is required",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			partialsDir := ""
+			if tc.partialsDir != "" {
+				partialsDir = filepath.Join(dir, tc.partialsDir)
+			}
+
+			msg, err := renderMessageTemplate(filepath.Join(dir, tc.file), partialsDir, build, tc.escape)
+			expectedErr := strings.Replace(tc.err, "{dir}", dir, -1)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != expectedErr) {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			got, _ := json.Marshal(msg)
+			expected, _ := json.Marshal(tc.output)
+			if !cmp.Equal(got, expected) {
+				t.Errorf("Returned message is not correct:\nexpected: %s\ngot:      %s", expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Names of the step outputs
+const (
+	threadKeyOutput   = "GOOGLE_CHAT_THREAD_KEY"
+	messageNameOutput = "GOOGLE_CHAT_MESSAGE_NAME"
+	threadNameOutput  = "GOOGLE_CHAT_THREAD_NAME"
+	spaceNameOutput   = "GOOGLE_CHAT_SPACE_NAME"
+	payloadPathOutput = "GOOGLE_CHAT_PAYLOAD_PATH"
+	outcomeOutput     = "GOOGLE_CHAT_OUTCOME"
+	errorPhaseOutput  = "GOOGLE_CHAT_ERROR_PHASE"
+)
+
+// exportOutput exports an environment variable for the following steps. Replaced in tests.
+var exportOutput = exportEnvironmentWithEnvman
+
+// exportEnvironmentWithEnvman exports an environment variable using envman. Outputs are skipped if envman is not installed, e.g. outside Bitrise.
+func exportEnvironmentWithEnvman(key, value string) error {
+	if _, err := exec.LookPath("envman"); err != nil {
+		log.Debugf("Skipping output %s, envman is not available", key)
+		return nil
+	}
+
+	cmd := exec.Command("envman", "add", "--key", key)
+	cmd.Stdin = strings.NewReader(value)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to export %s: %s, output: %s", key, err, out)
+	}
+
+	return nil
+}
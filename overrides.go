@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// aliasSuffixes map the suffix of the alias inputs, e.g. message_on_error, to the state they override
+var aliasSuffixes = map[string]BuildState{
+	"_on_error":         stateFailure,
+	"_on_fixed":         stateFixed,
+	"_on_still_failing": stateStillFailing,
+	"_on_aborted":       stateAborted,
+}
+
+var optPattern = regexp.MustCompile(`opt\[([^\]]*)\]`)
+
+// Overrides replace the values of inputs for a build state, keyed by state and input name, e.g.
+//
+//	{"failure": {"title": "Build failed"}, "fixed": {"title": "Build fixed"}}
+type Overrides map[BuildState]map[string]string
+
+// overridableInput is an input which can be overridden for a build state
+type overridableInput struct {
+	// Field is the index of the field of the input in the Config
+	Field int
+	// Options are the allowed values of the input, empty if any value is allowed
+	Options []string
+}
+
+// overridableInputNames are the inputs which can be overridden for a build state: the content of the message.
+// Inputs like the webhook url, the transport or the send rules are used before the build state is resolved.
+var overridableInputNames = []string{
+	"message",
+	"title",
+	"subtitle",
+	"image",
+	"image_style",
+	"text",
+	"key_value",
+	"images",
+	"buttons",
+	"sections",
+	"cards",
+	"cards_json",
+	"thread_key",
+}
+
+// overridableInputs returns the inputs which can be overridden, keyed by name
+func overridableInputs() map[string]overridableInput {
+	t := reflect.TypeOf(Config{})
+	inputs := map[string]overridableInput{}
+
+	for _, input := range configInputs() {
+		if !containsString(overridableInputNames, input.Name) {
+			continue
+		}
+
+		var options []string
+		if match := optPattern.FindStringSubmatch(t.Field(input.Field).Tag.Get("env")); match != nil {
+			options = strings.Split(match[1], ",")
+		}
+
+		inputs[input.Name] = overridableInput{Field: input.Field, Options: options}
+	}
+
+	return inputs
+}
+
+// aliasInput returns the input and the state overridden by an alias input, e.g. message and failure for message_on_error
+func aliasInput(name string) (string, BuildState, bool) {
+	for suffix, state := range aliasSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix), state, true
+		}
+	}
+	return "", "", false
+}
+
+// parseOverrides parses the overrides input, a JSON or YAML object of the overridden inputs keyed by build state
+func parseOverrides(raw string) (Overrides, error) {
+	overrides := Overrides{}
+	if strings.TrimSpace(raw) == "" {
+		return overrides, nil
+	}
+
+	if err := unmarshalJSONOrYAML(raw, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse overrides: %s", err)
+	}
+
+	validStates := map[BuildState]bool{}
+	for _, state := range buildStates {
+		validStates[state] = true
+	}
+	inputs := overridableInputs()
+
+	for state, values := range overrides {
+		if !validStates[state] {
+			return nil, fmt.Errorf("Unknown state %q in overrides, expected one of %s", state, joinStates(buildStates))
+		}
+
+		for name, value := range values {
+			input, ok := inputs[name]
+			if !ok {
+				return nil, fmt.Errorf("Input %q in overrides.%s can't be overridden", name, state)
+			}
+			if len(input.Options) > 0 && !containsString(input.Options, value) {
+				return nil, fmt.Errorf("Invalid value %q of %s in overrides.%s, expected one of %s", value, name, state, strings.Join(input.Options, ", "))
+			}
+		}
+	}
+
+	return overrides, nil
+}
+
+// stateChain returns the states whose overrides apply to a build state, from the most general to the most specific.
+// A fixed build is also successful, still failing and aborted builds are also failures.
+func stateChain(state BuildState) []BuildState {
+	switch state {
+	case stateFixed:
+		return []BuildState{stateSuccess, stateFixed}
+	case stateStillFailing, stateAborted:
+		return []BuildState{stateFailure, state}
+	default:
+		return []BuildState{state}
+	}
+}
+
+// resolveConfig returns the config with the overrides of the build state merged over the inputs.
+// The alias inputs, e.g. message_on_error, are added to the overrides unless the overrides input sets the same input.
+func resolveConfig(c Config, state BuildState) (Config, error) {
+	overrides, err := parseOverrides(c.Overrides)
+	if err != nil {
+		return c, err
+	}
+
+	v := reflect.ValueOf(&c).Elem()
+
+	for _, input := range configInputs() {
+		name, aliasState, ok := aliasInput(input.Name)
+		if !ok {
+			continue
+		}
+
+		value := v.Field(input.Field).String()
+		if value == "" {
+			continue
+		}
+
+		if overrides[aliasState] == nil {
+			overrides[aliasState] = map[string]string{}
+		}
+		if _, ok := overrides[aliasState][name]; !ok {
+			overrides[aliasState][name] = value
+		}
+	}
+
+	inputs := overridableInputs()
+	for _, s := range stateChain(state) {
+		for name, value := range overrides[s] {
+			input, ok := inputs[name]
+			if !ok {
+				return c, fmt.Errorf("Input %q in overrides.%s can't be overridden", name, s)
+			}
+			v.Field(input.Field).SetString(value)
+		}
+	}
+
+	return c, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
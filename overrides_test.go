@@ -0,0 +1,157 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_parseOverrides(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output Overrides
+		err    string
+	}{
+		{
+			name:   "Empty",
+			input:  "",
+			output: Overrides{},
+		},
+		{
+			name:  "Overrides by state",
+			input: `{"failure": {"title": "Build failed", "image_style": "circular"}, "fixed": {"text": "Back to green"}}`,
+			output: Overrides{
+				stateFailure: {"title": "Build failed", "image_style": "circular"},
+				stateFixed:   {"text": "Back to green"},
+			},
+		},
+		{
+			name:  "YAML",
+			input: "failure:\n  title: Build failed\n  image_style: circular\nfixed:\n  text: |\n    Back to green\n    after a failure\n",
+			output: Overrides{
+				stateFailure: {"title": "Build failed", "image_style": "circular"},
+				stateFixed:   {"text": "Back to green\nafter a failure\n"},
+			},
+		},
+		{
+			name:  "Invalid JSON",
+			input: `{"failure": {"title": "Build failed"}`,
+			err:   "failed to parse overrides: unexpected end of JSON input",
+		},
+		{
+			name:  "Unknown state",
+			input: `{"error": {"title": "Build failed"}}`,
+			err:   `Unknown state "error" in overrides, expected one of success, failure, fixed, still_failing, aborted`,
+		},
+		{
+			name:  "Alias input",
+			input: `{"failure": {"title_on_error": "Build failed"}}`,
+			err:   `Input "title_on_error" in overrides.failure can't be overridden`,
+		},
+		{
+			name:  "Yes/no input",
+			input: `{"failure": {"dry_run": "yes"}}`,
+			err:   `Input "dry_run" in overrides.failure can't be overridden`,
+		},
+		{
+			name:  "Secret input",
+			input: `{"failure": {"webhook_url": "https://chat.googleapis.com/v1/spaces/AAA/messages"}}`,
+			err:   `Input "webhook_url" in overrides.failure can't be overridden`,
+		},
+		{
+			name:  "Delivery input",
+			input: `{"failure": {"transport": "api"}}`,
+			err:   `Input "transport" in overrides.failure can't be overridden`,
+		},
+		{
+			name:  "Send rule input",
+			input: `{"aborted": {"send_when": "failure"}}`,
+			err:   `Input "send_when" in overrides.aborted can't be overridden`,
+		},
+		{
+			name:  "Invalid option",
+			input: `{"failure": {"image_style": "round"}}`,
+			err:   `Invalid value "round" of image_style in overrides.failure, expected one of square, circular`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			overrides, err := parseOverrides(tc.input)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if !cmp.Equal(overrides, tc.output) {
+				t.Errorf("Returned overrides are not correct: %s", cmp.Diff(tc.output, overrides))
+			}
+		})
+	}
+}
+
+func Test_resolveConfig(t *testing.T) {
+	config := Config{
+		Title:           "Build",
+		TitleOnError:    "Build failed",
+		Subtitle:        "main",
+		ImageURL:        "https://example.org/success.png",
+		ImageURLOnFixed: "https://example.org/fixed.png",
+		Overrides: `{
+			"failure": {"subtitle": "main is broken", "title": "Build is red"},
+			"still_failing": {"subtitle": "main is still broken"},
+			"fixed": {"title": "Build fixed"}
+		}`,
+	}
+
+	tests := []struct {
+		state    BuildState
+		title    string
+		subtitle string
+		image    string
+	}{
+		{state: stateSuccess, title: "Build", subtitle: "main", image: "https://example.org/success.png"},
+		{state: stateFailure, title: "Build is red", subtitle: "main is broken", image: "https://example.org/success.png"},
+		{state: stateFixed, title: "Build fixed", subtitle: "main", image: "https://example.org/fixed.png"},
+		{state: stateStillFailing, title: "Build is red", subtitle: "main is still broken", image: "https://example.org/success.png"},
+		{state: stateAborted, title: "Build is red", subtitle: "main is broken", image: "https://example.org/success.png"},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.state), func(t *testing.T) {
+			resolved, err := resolveConfig(config, tc.state)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if resolved.Title != tc.title || resolved.Subtitle != tc.subtitle || resolved.ImageURL != tc.image {
+				t.Errorf("Resolved config is not correct: expected %q, %q, %q, got %q, %q, %q",
+					tc.title, tc.subtitle, tc.image, resolved.Title, resolved.Subtitle, resolved.ImageURL)
+			}
+		})
+	}
+}
+
+func Test_resolveConfig_aliases(t *testing.T) {
+	config := Config{
+		Message:          "passed",
+		MessageOnError:   "failed",
+		KeyValue:         "success key values",
+		KeyValueOnError:  "failure key values",
+		CardsJSONOnError: `{"cards": []}`,
+	}
+
+	resolved, err := resolveConfig(config, stateFailure)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if resolved.Message != "failed" || resolved.KeyValue != "failure key values" || resolved.CardsJSON != `{"cards": []}` {
+		t.Errorf("Aliases are not applied: %+v", resolved)
+	}
+	if config.Message != "passed" {
+		t.Errorf("The original config is modified: %+v", config)
+	}
+}
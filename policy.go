@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Values of the failure_policy input
+const (
+	failPolicy   = "fail"
+	warnPolicy   = "warn"
+	ignorePolicy = "ignore"
+)
+
+// Phases of the step
+const (
+	parsePhase    = "parse"
+	validatePhase = "validate"
+	renderPhase   = "render"
+	deliverPhase  = "deliver"
+)
+
+// Values of the GOOGLE_CHAT_OUTCOME output
+const (
+	sentOutcome       = "sent"
+	dryRunOutcome     = "dry_run"
+	suppressedOutcome = "suppressed"
+	skippedOutcome    = "skipped"
+	failedOutcome     = "failed"
+)
+
+// PhaseError is an error which stopped the step in one of its phases
+type PhaseError struct {
+	Phase string
+	Err   error
+}
+
+func (e *PhaseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Phase, e.Err)
+}
+
+// finish exports the outcome of the step and returns its exit code.
+// An error only fails the step if the failure policy is fail, which is the default.
+func finish(policy, outcome string, err error) int {
+	code := 0
+
+	if err != nil {
+		phase := "unknown"
+		if phaseErr, ok := err.(*PhaseError); ok {
+			phase, err = phaseErr.Phase, phaseErr.Err
+		}
+
+		switch policy {
+		case warnPolicy:
+			outcome = skippedOutcome
+			log.Warnf("\nGoogle Chat message skipped (failure_policy: %s)", policy)
+			log.Warnf("- phase:  %s", phase)
+			log.Warnf("- reason: %s", err)
+			log.Warnf("The build continues, as the failure policy doesn't allow the message to fail it.\n")
+		case ignorePolicy:
+			outcome = skippedOutcome
+			log.Printf("Google Chat message skipped in phase %s (failure_policy: %s)", phase, policy)
+		default:
+			outcome = failedOutcome
+			code = 1
+			log.Errorf("Error: %s\n", err)
+		}
+
+		if exportErr := exportOutput(errorPhaseOutput, phase); exportErr != nil {
+			log.Warnf("Warning: %s", exportErr)
+		}
+	}
+
+	if exportErr := exportOutput(outcomeOutput, outcome); exportErr != nil {
+		log.Warnf("Warning: %s", exportErr)
+	}
+
+	return code
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_finish(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		outcome string
+		err     error
+		code    int
+		outputs map[string]string
+	}{
+		{
+			name:    "Sent",
+			policy:  failPolicy,
+			outcome: sentOutcome,
+			code:    0,
+			outputs: map[string]string{"GOOGLE_CHAT_OUTCOME": "sent"},
+		},
+		{
+			name:    "Suppressed",
+			policy:  failPolicy,
+			outcome: suppressedOutcome,
+			code:    0,
+			outputs: map[string]string{"GOOGLE_CHAT_OUTCOME": "suppressed"},
+		},
+		{
+			name:    "Fail",
+			policy:  failPolicy,
+			err:     &PhaseError{Phase: deliverPhase, Err: errors.New("server error")},
+			code:    1,
+			outputs: map[string]string{"GOOGLE_CHAT_OUTCOME": "failed", "GOOGLE_CHAT_ERROR_PHASE": "deliver"},
+		},
+		{
+			name:    "Fail by default",
+			policy:  "",
+			err:     &PhaseError{Phase: parsePhase, Err: errors.New("failed to parse config")},
+			code:    1,
+			outputs: map[string]string{"GOOGLE_CHAT_OUTCOME": "failed", "GOOGLE_CHAT_ERROR_PHASE": "parse"},
+		},
+		{
+			name:    "Warn",
+			policy:  warnPolicy,
+			err:     &PhaseError{Phase: renderPhase, Err: errors.New("invalid character")},
+			code:    0,
+			outputs: map[string]string{"GOOGLE_CHAT_OUTCOME": "skipped", "GOOGLE_CHAT_ERROR_PHASE": "render"},
+		},
+		{
+			name:    "Ignore",
+			policy:  ignorePolicy,
+			err:     &PhaseError{Phase: validatePhase, Err: errors.New("WebhookURL is empty")},
+			code:    0,
+			outputs: map[string]string{"GOOGLE_CHAT_OUTCOME": "skipped", "GOOGLE_CHAT_ERROR_PHASE": "validate"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			exported := map[string]string{}
+			exportOutput = func(key, value string) error {
+				exported[key] = value
+				return nil
+			}
+			defer func() { exportOutput = exportEnvironmentWithEnvman }()
+
+			if code := finish(tc.policy, tc.outcome, tc.err); code != tc.code {
+				t.Errorf("Exit code is not correct: expected %d, got %d", tc.code, code)
+			}
+
+			if !cmp.Equal(exported, tc.outputs) {
+				t.Errorf("Exported outputs are not correct: expected %+v, got %+v", tc.outputs, exported)
+			}
+		})
+	}
+}
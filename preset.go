@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"reflect"
+)
+
+// Values of the preset input
+const (
+	noPreset          = "none"
+	buildReportPreset = "build-report"
+)
+
+// stateDescriptions describe the build states in the subtitle of the build-report preset
+var stateDescriptions = map[BuildState]string{
+	stateSuccess:      "succeeded",
+	stateFailure:      "failed",
+	stateFixed:        "is fixed",
+	stateStillFailing: "is still failing",
+	stateAborted:      "was aborted",
+}
+
+// buildReportInputs returns the inputs of the build-report preset: a header with the app and the build status,
+// key values with the workflow, branch, commit and author, and a button opening the build
+func buildReportInputs(build BuildContext) (map[string]string, error) {
+	title := firstNonEmpty(build.AppTitle, "Build")
+
+	subtitle := "Build"
+	if build.BuildNumber != "" {
+		subtitle += " #" + build.BuildNumber
+	}
+	subtitle += " " + stateDescriptions[build.State]
+
+	var keyValues []KeyValueInput
+	add := func(label, content, icon string) {
+		if content != "" {
+			keyValues = append(keyValues, KeyValueInput{
+				TopLabel:         label,
+				Content:          html.EscapeString(content),
+				ContentMultiline: true,
+				Icon:             icon,
+			})
+		}
+	}
+	add("Workflow", build.Workflow, "DESCRIPTION")
+	if build.Tag != "" {
+		add("Tag", build.Tag, "BOOKMARK")
+	} else {
+		add("Branch", build.Branch, "BOOKMARK")
+	}
+	add("Commit", build.CommitMessage, "")
+	add("Author", build.CommitAuthor, "PERSON")
+
+	inputs := map[string]string{
+		"message":  title + ": " + subtitle,
+		"title":    title,
+		"subtitle": subtitle,
+	}
+
+	if len(keyValues) > 0 {
+		b, err := json.Marshal(keyValues)
+		if err != nil {
+			return nil, err
+		}
+		inputs["key_value"] = string(b)
+	}
+
+	if build.BuildURL != "" {
+		inputs["buttons"] = "text|View build|" + build.BuildURL
+	}
+
+	return inputs, nil
+}
+
+// applyPreset fills the inputs which are empty with the values of the preset, so every part of it can be replaced by an input
+func applyPreset(c Config, build BuildContext) (Config, error) {
+	if c.Preset != buildReportPreset {
+		return c, nil
+	}
+
+	preset, err := buildReportInputs(build)
+	if err != nil {
+		return c, err
+	}
+
+	v := reflect.ValueOf(&c).Elem()
+	inputs := overridableInputs()
+
+	for name, value := range preset {
+		field := v.Field(inputs[name].Field)
+		if field.String() == "" {
+			field.SetString(value)
+		}
+	}
+
+	return c, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_newMessage_buildReportPreset(t *testing.T) {
+	build := BuildContext{
+		Status:        statusFailed,
+		State:         stateStillFailing,
+		Workflow:      "primary",
+		Branch:        "main",
+		CommitMessage: "Fix <b>{{ .Branch }}</b>",
+		CommitAuthor:  "Jane",
+		BuildNumber:   "42",
+		BuildURL:      "https://app.bitrise.io/build/1",
+		AppTitle:      "Android",
+	}
+
+	keyValue := func(label, content, icon string) *googlechat.Widget {
+		return &googlechat.Widget{KeyValue: &googlechat.KeyValue{TopLabel: label, Content: content, ContentMultiline: "true", Icon: icon}}
+	}
+	viewBuild := []*googlechat.Widget{{Buttons: []*googlechat.Button{googlechat.NewTextButton("View build", "https://app.bitrise.io/build/1")}}}
+
+	tests := []struct {
+		name   string
+		config Config
+		output googlechat.Message
+	}{
+		{
+			name:   "Preset",
+			config: Config{Preset: buildReportPreset},
+			output: googlechat.Message{
+				Text: "Android: Build #42 is still failing",
+				Cards: []googlechat.Card{{
+					Header: &googlechat.Header{Title: "Android", Subtitle: "Build #42 is still failing"},
+					Sections: []googlechat.Section{
+						{Widgets: []*googlechat.Widget{
+							keyValue("Workflow", "primary", "DESCRIPTION"),
+							keyValue("Branch", "main", "BOOKMARK"),
+							keyValue("Commit", "Fix &lt;b&gt;{{ .Branch }}&lt;/b&gt;", ""),
+							keyValue("Author", "Jane", "PERSON"),
+						}},
+						{Widgets: viewBuild},
+					},
+				}},
+			},
+		},
+		{
+			name: "Inputs replace parts of the preset",
+			config: Config{
+				Preset:       buildReportPreset,
+				TitleOnError: "{{ .AppTitle }} is broken",
+				KeyValue:     `[{"topLabel":"Branch","content":"{{ .Branch }}"}]`,
+			},
+			output: googlechat.Message{
+				Text: "Android: Build #42 is still failing",
+				Cards: []googlechat.Card{{
+					Header: &googlechat.Header{Title: "Android is broken", Subtitle: "Build #42 is still failing"},
+					Sections: []googlechat.Section{
+						{Widgets: []*googlechat.Widget{{KeyValue: &googlechat.KeyValue{TopLabel: "Branch", Content: "main", ContentMultiline: "false"}}}},
+						{Widgets: viewBuild},
+					},
+				}},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			msg, err := newMessage(tc.config, build)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			got, _ := json.Marshal(msg)
+			expected, _ := json.Marshal(tc.output)
+			if !cmp.Equal(got, expected) {
+				t.Errorf("Returned message is not correct:\nexpected: %s\ngot:      %s", expected, got)
+			}
+		})
+	}
+}
+
+func Test_buildReportInputs(t *testing.T) {
+	inputs, err := buildReportInputs(BuildContext{Status: statusSuccess, State: stateSuccess, Tag: "v1.0.0", Workflow: "deploy"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[string]string{
+		"message":   "Build: Build succeeded",
+		"title":     "Build",
+		"subtitle":  "Build succeeded",
+		"key_value": `[{"topLabel":"Workflow","content":"deploy","contentMultiline":true,"icon":"DESCRIPTION"},{"topLabel":"Tag","content":"v1.0.0","contentMultiline":true,"icon":"BOOKMARK"}]`,
+	}
+	if !cmp.Equal(inputs, expected) {
+		t.Errorf("Returned inputs are not correct: %s", cmp.Diff(expected, inputs))
+	}
+}
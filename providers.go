@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of the CI providers, set as the Provider of a BuildContext
+const (
+	bitriseName       = "bitrise"
+	githubActionsName = "github-actions"
+	gitlabName        = "gitlab"
+	jenkinsName       = "jenkins"
+	circleCIName      = "circleci"
+)
+
+// detectProvider returns the provider of the CI running the step, falling back to Bitrise
+func detectProvider(getenv func(string) string) BuildContextProvider {
+	switch {
+	case getenv("BITRISE_BUILD_NUMBER") != "" || getenv("BITRISE_IO") != "":
+		return bitriseProvider{getenv: getenv}
+	case getenv("GITHUB_ACTIONS") == "true":
+		return githubActionsProvider{getenv: getenv}
+	case getenv("GITLAB_CI") == "true":
+		return gitlabProvider{getenv: getenv}
+	case getenv("JENKINS_URL") != "":
+		return jenkinsProvider{getenv: getenv}
+	case getenv("CIRCLECI") == "true":
+		return circleCIProvider{getenv: getenv}
+	default:
+		return bitriseProvider{getenv: getenv}
+	}
+}
+
+// readBuildContext reads the context of the build from the CI provider, the build_status input overrides its status.
+// Providers which don't know the status leave it empty, it has to be set using build_status, as an unknown status
+// would be sent as a failed build.
+func readBuildContext(conf Config, getenv func(string) string) (BuildContext, error) {
+	build := detectProvider(getenv).BuildContext()
+	if conf.BuildStatus != "" {
+		build.Status = parseBuildStatus(conf.BuildStatus)
+	}
+
+	if build.Status == "" {
+		return build, fmt.Errorf("BuildStatus is empty. You need to provide one, as the status of the build is not available on %s", build.Provider)
+	}
+
+	return build, nil
+}
+
+// bitriseProvider reads the context of a Bitrise build from its environment variables
+type bitriseProvider struct {
+	getenv func(string) string
+}
+
+// BuildContext implements BuildContextProvider.BuildContext
+func (p bitriseProvider) BuildContext() BuildContext {
+	status := statusFailed
+	if p.getenv("BITRISE_BUILD_STATUS") == "0" {
+		status = statusSuccess
+	}
+
+	return BuildContext{
+		Provider:      bitriseName,
+		Status:        status,
+		Workflow:      p.getenv("BITRISE_TRIGGERED_WORKFLOW_ID"),
+		Branch:        p.getenv("BITRISE_GIT_BRANCH"),
+		Tag:           p.getenv("BITRISE_GIT_TAG"),
+		Commit:        firstNonEmpty(p.getenv("BITRISE_GIT_COMMIT"), p.getenv("GIT_CLONE_COMMIT_HASH")),
+		CommitMessage: firstNonEmpty(p.getenv("BITRISE_GIT_MESSAGE"), p.getenv("GIT_CLONE_COMMIT_MESSAGE_SUBJECT")),
+		CommitAuthor:  p.getenv("GIT_CLONE_COMMIT_AUTHOR_NAME"),
+		PullRequest:   p.getenv("BITRISE_PULL_REQUEST"),
+		BuildNumber:   p.getenv("BITRISE_BUILD_NUMBER"),
+		BuildURL:      p.getenv("BITRISE_BUILD_URL"),
+		AppSlug:       p.getenv("BITRISE_APP_SLUG"),
+		AppTitle:      p.getenv("BITRISE_APP_TITLE"),
+		AppURL:        p.getenv("BITRISE_APP_URL"),
+		TriggeredAt:   parseUnixTime(p.getenv("BITRISE_BUILD_TRIGGER_TIMESTAMP")),
+		Now:           now(),
+	}
+}
+
+// githubActionsProvider reads the context of a GitHub Actions run from its environment variables.
+// The job status is not available in the environment, it has to be passed using the build_status input.
+type githubActionsProvider struct {
+	getenv func(string) string
+}
+
+// BuildContext implements BuildContextProvider.BuildContext
+func (p githubActionsProvider) BuildContext() BuildContext {
+	repoURL := p.getenv("GITHUB_SERVER_URL") + "/" + p.getenv("GITHUB_REPOSITORY")
+
+	build := BuildContext{
+		Provider:     githubActionsName,
+		Workflow:     p.getenv("GITHUB_WORKFLOW"),
+		Commit:       p.getenv("GITHUB_SHA"),
+		CommitAuthor: p.getenv("GITHUB_ACTOR"),
+		BuildNumber:  p.getenv("GITHUB_RUN_NUMBER"),
+		BuildURL:     repoURL + "/actions/runs/" + p.getenv("GITHUB_RUN_ID"),
+		AppSlug:      p.getenv("GITHUB_REPOSITORY"),
+		AppTitle:     path.Base(p.getenv("GITHUB_REPOSITORY")),
+		AppURL:       repoURL,
+		Now:          now(),
+	}
+
+	if p.getenv("GITHUB_REF_TYPE") == "tag" {
+		build.Tag = p.getenv("GITHUB_REF_NAME")
+	} else {
+		// Pull request runs are on the merge ref, the head ref is the branch of the pull request
+		build.Branch = firstNonEmpty(p.getenv("GITHUB_HEAD_REF"), p.getenv("GITHUB_REF_NAME"))
+	}
+
+	// refs/pull/42/merge
+	if ref := strings.Split(p.getenv("GITHUB_REF"), "/"); len(ref) == 4 && ref[1] == "pull" {
+		build.PullRequest = ref[2]
+	}
+
+	return build
+}
+
+// gitlabProvider reads the context of a GitLab CI job from its environment variables.
+// The job status is only available in after_script, it is running in the script of the job.
+type gitlabProvider struct {
+	getenv func(string) string
+}
+
+// BuildContext implements BuildContextProvider.BuildContext
+func (p gitlabProvider) BuildContext() BuildContext {
+	var triggeredAt time.Time
+	if t, err := time.Parse(time.RFC3339, p.getenv("CI_PIPELINE_CREATED_AT")); err == nil {
+		triggeredAt = t
+	}
+
+	var status BuildStatus
+	if jobStatus := p.getenv("CI_JOB_STATUS"); jobStatus != "" && jobStatus != "running" {
+		status = parseBuildStatus(jobStatus)
+	}
+
+	return BuildContext{
+		Provider:      gitlabName,
+		Status:        status,
+		Workflow:      firstNonEmpty(p.getenv("CI_PIPELINE_NAME"), p.getenv("CI_JOB_NAME")),
+		Branch:        firstNonEmpty(p.getenv("CI_COMMIT_BRANCH"), p.getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME")),
+		Tag:           p.getenv("CI_COMMIT_TAG"),
+		Commit:        p.getenv("CI_COMMIT_SHA"),
+		CommitMessage: p.getenv("CI_COMMIT_TITLE"),
+		CommitAuthor:  p.getenv("CI_COMMIT_AUTHOR"),
+		PullRequest:   p.getenv("CI_MERGE_REQUEST_IID"),
+		BuildNumber:   p.getenv("CI_PIPELINE_IID"),
+		BuildURL:      p.getenv("CI_PIPELINE_URL"),
+		AppSlug:       p.getenv("CI_PROJECT_PATH"),
+		AppTitle:      p.getenv("CI_PROJECT_NAME"),
+		AppURL:        p.getenv("CI_PROJECT_URL"),
+		TriggeredAt:   triggeredAt,
+		Now:           now(),
+	}
+}
+
+// jenkinsProvider reads the context of a Jenkins build from its environment variables.
+// The build result is not available in the environment, it has to be passed using the build_status input.
+type jenkinsProvider struct {
+	getenv func(string) string
+}
+
+// BuildContext implements BuildContextProvider.BuildContext
+func (p jenkinsProvider) BuildContext() BuildContext {
+	return BuildContext{
+		Provider:    jenkinsName,
+		Workflow:    p.getenv("JOB_NAME"),
+		Branch:      firstNonEmpty(p.getenv("CHANGE_BRANCH"), p.getenv("BRANCH_NAME"), strings.TrimPrefix(p.getenv("GIT_BRANCH"), "origin/")),
+		Tag:         p.getenv("TAG_NAME"),
+		Commit:      p.getenv("GIT_COMMIT"),
+		PullRequest: p.getenv("CHANGE_ID"),
+		BuildNumber: p.getenv("BUILD_NUMBER"),
+		BuildURL:    p.getenv("BUILD_URL"),
+		AppSlug:     p.getenv("JOB_NAME"),
+		AppTitle:    p.getenv("JOB_BASE_NAME"),
+		AppURL:      p.getenv("JOB_URL"),
+		Now:         now(),
+	}
+}
+
+// circleCIProvider reads the context of a CircleCI job from its environment variables.
+// The job status is not available in the environment, it has to be passed using the build_status input.
+type circleCIProvider struct {
+	getenv func(string) string
+}
+
+// BuildContext implements BuildContextProvider.BuildContext
+func (p circleCIProvider) BuildContext() BuildContext {
+	build := BuildContext{
+		Provider:     circleCIName,
+		Workflow:     p.getenv("CIRCLE_JOB"),
+		Branch:       p.getenv("CIRCLE_BRANCH"),
+		Tag:          p.getenv("CIRCLE_TAG"),
+		Commit:       p.getenv("CIRCLE_SHA1"),
+		CommitAuthor: p.getenv("CIRCLE_USERNAME"),
+		BuildNumber:  p.getenv("CIRCLE_BUILD_NUM"),
+		BuildURL:     p.getenv("CIRCLE_BUILD_URL"),
+		AppSlug:      p.getenv("CIRCLE_PROJECT_USERNAME") + "/" + p.getenv("CIRCLE_PROJECT_REPONAME"),
+		AppTitle:     p.getenv("CIRCLE_PROJECT_REPONAME"),
+		AppURL:       p.getenv("CIRCLE_REPOSITORY_URL"),
+		Now:          now(),
+	}
+
+	// https://github.com/org/repo/pull/42
+	if pullRequest := p.getenv("CIRCLE_PULL_REQUEST"); pullRequest != "" {
+		build.PullRequest = path.Base(pullRequest)
+	}
+
+	return build
+}
+
+// parseUnixTime parses a unix timestamp in seconds, returning the zero time if it is invalid
+func parseUnixTime(s string) time.Time {
+	seconds, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
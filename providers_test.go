@@ -0,0 +1,300 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_bitriseProvider(t *testing.T) {
+	fixedNow := time.Date(2020, 6, 23, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixedNow }
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		name   string
+		env    map[string]string
+		output BuildContext
+	}{
+		{
+			name: "Successful build",
+			env: map[string]string{
+				"BITRISE_BUILD_STATUS":             "0",
+				"BITRISE_TRIGGERED_WORKFLOW_ID":    "primary",
+				"BITRISE_GIT_BRANCH":               "main",
+				"GIT_CLONE_COMMIT_HASH":            "abc123",
+				"GIT_CLONE_COMMIT_MESSAGE_SUBJECT": "Fix the build",
+				"BITRISE_BUILD_NUMBER":             "42",
+				"BITRISE_BUILD_URL":                "https://app.bitrise.io/build/slug",
+				"BITRISE_BUILD_TRIGGER_TIMESTAMP":  "1592913000",
+			},
+			output: BuildContext{
+				Provider:      bitriseName,
+				Status:        statusSuccess,
+				Workflow:      "primary",
+				Branch:        "main",
+				Commit:        "abc123",
+				CommitMessage: "Fix the build",
+				BuildNumber:   "42",
+				BuildURL:      "https://app.bitrise.io/build/slug",
+				TriggeredAt:   time.Unix(1592913000, 0),
+				Now:           fixedNow,
+			},
+		},
+		{
+			name: "Failed build",
+			env: map[string]string{
+				"BITRISE_BUILD_STATUS": "1",
+				"BITRISE_GIT_COMMIT":   "def456",
+			},
+			output: BuildContext{
+				Provider: bitriseName,
+				Status:   statusFailed,
+				Commit:   "def456",
+				Now:      fixedNow,
+			},
+		},
+		{
+			name: "Unknown status",
+			env:  map[string]string{},
+			output: BuildContext{
+				Provider: bitriseName,
+				Status:   statusFailed,
+				Now:      fixedNow,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := bitriseProvider{getenv: func(key string) string { return tc.env[key] }}
+
+			if build := provider.BuildContext(); !cmp.Equal(build, tc.output) {
+				t.Errorf("Build context is not correct: %s", cmp.Diff(tc.output, build))
+			}
+		})
+	}
+}
+
+func Test_detectProvider(t *testing.T) {
+	fixedNow := time.Date(2020, 6, 23, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixedNow }
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		name   string
+		env    map[string]string
+		output BuildContext
+	}{
+		{
+			name: "GitHub Actions pull request",
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_WORKFLOW":   "CI",
+				"GITHUB_REF":        "refs/pull/42/merge",
+				"GITHUB_REF_NAME":   "42/merge",
+				"GITHUB_HEAD_REF":   "feature/chat",
+				"GITHUB_SHA":        "abc123",
+				"GITHUB_ACTOR":      "octocat",
+				"GITHUB_RUN_ID":     "1234",
+				"GITHUB_RUN_NUMBER": "7",
+				"GITHUB_SERVER_URL": "https://github.com",
+				"GITHUB_REPOSITORY": "org/app",
+			},
+			output: BuildContext{
+				Provider:     githubActionsName,
+				Workflow:     "CI",
+				Branch:       "feature/chat",
+				Commit:       "abc123",
+				CommitAuthor: "octocat",
+				PullRequest:  "42",
+				BuildNumber:  "7",
+				BuildURL:     "https://github.com/org/app/actions/runs/1234",
+				AppSlug:      "org/app",
+				AppTitle:     "app",
+				AppURL:       "https://github.com/org/app",
+				Now:          fixedNow,
+			},
+		},
+		{
+			name: "GitHub Actions tag",
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_REF":        "refs/tags/v1.0.0",
+				"GITHUB_REF_NAME":   "v1.0.0",
+				"GITHUB_REF_TYPE":   "tag",
+				"GITHUB_SERVER_URL": "https://github.com",
+				"GITHUB_REPOSITORY": "org/app",
+				"GITHUB_RUN_ID":     "1234",
+			},
+			output: BuildContext{
+				Provider: githubActionsName,
+				Tag:      "v1.0.0",
+				BuildURL: "https://github.com/org/app/actions/runs/1234",
+				AppSlug:  "org/app",
+				AppTitle: "app",
+				AppURL:   "https://github.com/org/app",
+				Now:      fixedNow,
+			},
+		},
+		{
+			name: "GitLab CI merge request",
+			env: map[string]string{
+				"GITLAB_CI":                           "true",
+				"CI_JOB_STATUS":                       "success",
+				"CI_JOB_NAME":                         "test",
+				"CI_MERGE_REQUEST_SOURCE_BRANCH_NAME": "feature/chat",
+				"CI_MERGE_REQUEST_IID":                "12",
+				"CI_COMMIT_SHA":                       "abc123",
+				"CI_COMMIT_TITLE":                     "Add chat",
+				"CI_PIPELINE_IID":                     "99",
+				"CI_PIPELINE_URL":                     "https://gitlab.com/org/app/-/pipelines/1",
+				"CI_PIPELINE_CREATED_AT":              "2020-06-23T11:00:00Z",
+				"CI_PROJECT_PATH":                     "org/app",
+				"CI_PROJECT_NAME":                     "app",
+			},
+			output: BuildContext{
+				Provider:      gitlabName,
+				Status:        statusSuccess,
+				Workflow:      "test",
+				Branch:        "feature/chat",
+				Commit:        "abc123",
+				CommitMessage: "Add chat",
+				PullRequest:   "12",
+				BuildNumber:   "99",
+				BuildURL:      "https://gitlab.com/org/app/-/pipelines/1",
+				AppSlug:       "org/app",
+				AppTitle:      "app",
+				TriggeredAt:   time.Date(2020, 6, 23, 11, 0, 0, 0, time.UTC),
+				Now:           fixedNow,
+			},
+		},
+		{
+			name: "Jenkins",
+			env: map[string]string{
+				"JENKINS_URL":   "https://jenkins.example.org/",
+				"JOB_NAME":      "app/main",
+				"JOB_BASE_NAME": "main",
+				"GIT_BRANCH":    "origin/main",
+				"GIT_COMMIT":    "abc123",
+				"BUILD_NUMBER":  "5",
+				"BUILD_URL":     "https://jenkins.example.org/job/app/5/",
+			},
+			output: BuildContext{
+				Provider:    jenkinsName,
+				Status:      "",
+				Workflow:    "app/main",
+				Branch:      "main",
+				Commit:      "abc123",
+				BuildNumber: "5",
+				BuildURL:    "https://jenkins.example.org/job/app/5/",
+				AppSlug:     "app/main",
+				AppTitle:    "main",
+				Now:         fixedNow,
+			},
+		},
+		{
+			name: "CircleCI",
+			env: map[string]string{
+				"CIRCLECI":                "true",
+				"CIRCLE_JOB":              "build",
+				"CIRCLE_BRANCH":           "feature/chat",
+				"CIRCLE_SHA1":             "abc123",
+				"CIRCLE_PULL_REQUEST":     "https://github.com/org/app/pull/42",
+				"CIRCLE_BUILD_NUM":        "8",
+				"CIRCLE_BUILD_URL":        "https://circleci.com/gh/org/app/8",
+				"CIRCLE_PROJECT_USERNAME": "org",
+				"CIRCLE_PROJECT_REPONAME": "app",
+			},
+			output: BuildContext{
+				Provider:    circleCIName,
+				Workflow:    "build",
+				Branch:      "feature/chat",
+				Commit:      "abc123",
+				PullRequest: "42",
+				BuildNumber: "8",
+				BuildURL:    "https://circleci.com/gh/org/app/8",
+				AppSlug:     "org/app",
+				AppTitle:    "app",
+				Now:         fixedNow,
+			},
+		},
+		{
+			name:   "Unknown provider",
+			env:    map[string]string{},
+			output: BuildContext{Provider: bitriseName, Status: statusFailed, Now: fixedNow},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := detectProvider(func(key string) string { return tc.env[key] })
+
+			if build := provider.BuildContext(); !cmp.Equal(build, tc.output) {
+				t.Errorf("Build context is not correct: %s", cmp.Diff(tc.output, build))
+			}
+		})
+	}
+}
+
+func Test_readBuildContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		buildStatus string
+		status      BuildStatus
+		err         string
+	}{
+		{
+			name:   "Bitrise",
+			env:    map[string]string{"BITRISE_BUILD_NUMBER": "1", "BITRISE_BUILD_STATUS": "0"},
+			status: statusSuccess,
+		},
+		{
+			name: "GitHub Actions without build_status",
+			env:  map[string]string{"GITHUB_ACTIONS": "true"},
+			err:  "BuildStatus is empty. You need to provide one, as the status of the build is not available on github-actions",
+		},
+		{
+			name:        "GitHub Actions with build_status",
+			env:         map[string]string{"GITHUB_ACTIONS": "true"},
+			buildStatus: "success",
+			status:      statusSuccess,
+		},
+		{
+			name: "GitLab CI script",
+			env:  map[string]string{"GITLAB_CI": "true", "CI_JOB_STATUS": "running"},
+			err:  "BuildStatus is empty. You need to provide one, as the status of the build is not available on gitlab",
+		},
+		{
+			name:   "GitLab CI after_script",
+			env:    map[string]string{"GITLAB_CI": "true", "CI_JOB_STATUS": "failed"},
+			status: statusFailed,
+		},
+		{
+			name: "Jenkins without build_status",
+			env:  map[string]string{"JENKINS_URL": "https://jenkins.example.org/"},
+			err:  "BuildStatus is empty. You need to provide one, as the status of the build is not available on jenkins",
+		},
+		{
+			name: "CircleCI without build_status",
+			env:  map[string]string{"CIRCLECI": "true"},
+			err:  "BuildStatus is empty. You need to provide one, as the status of the build is not available on circleci",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			build, err := readBuildContext(Config{BuildStatus: tc.buildStatus}, func(key string) string { return tc.env[key] })
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if tc.err == "" && build.Status != tc.status {
+				t.Errorf("Status is not correct: expected %s, got %s", tc.status, build.Status)
+			}
+		})
+	}
+}
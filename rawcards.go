@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+)
+
+// rawCards is the input format of the cards_json input and the cards_file
+type rawCards struct {
+	Cards   []googlechat.Card       `json:"cards,omitempty"`
+	CardsV2 []googlechat.CardWithID `json:"cardsV2,omitempty"`
+}
+
+// ParseRawCards parses the cards of a message supplied as JSON. The JSON is either an object with a cards or
+// cardsV2 array, or a bare array which is read as cards or cardsV2 depending on the card version.
+func ParseRawCards(raw string, cardVersion string) (v1 []googlechat.Card, v2 []googlechat.CardWithID, err error) {
+	raw = strings.TrimSpace(raw)
+
+	var parsed rawCards
+	if strings.HasPrefix(raw, "[") {
+		if cardVersion == cardsV2 {
+			err = decodeStrict(raw, &parsed.CardsV2)
+		} else {
+			err = decodeStrict(raw, &parsed.Cards)
+		}
+	} else {
+		err = decodeStrict(raw, &parsed)
+	}
+	if err != nil {
+		err = fmt.Errorf("Could not parse cards: %s", err)
+		return
+	}
+
+	if err = parsed.validate(); err != nil {
+		return
+	}
+
+	return parsed.Cards, parsed.CardsV2, nil
+}
+
+// validate checks the cards against the Chat card structure, reporting all problems at once
+func (r rawCards) validate() error {
+	if len(r.Cards) > 0 && len(r.CardsV2) > 0 {
+		return fmt.Errorf("Could not parse cards: either cards or cardsV2 should be provided, not both")
+	}
+
+	var problems []string
+	if len(r.CardsV2) > 0 {
+		problems = validateCardsV2(r.CardsV2)
+	} else {
+		problems = validateCards(r.Cards)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("Invalid cards:\n- %s", strings.Join(problems, "\n- "))
+	}
+
+	return nil
+}
+
+// readRawCards returns the cards JSON from the input, or from the file if the input is empty
+func readRawCards(raw, path string) (string, error) {
+	if raw != "" || path == "" {
+		return raw, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Could not read cards file: %s", err)
+	}
+
+	return string(b), nil
+}
+
+func decodeStrict(raw string, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// cardsValidator collects the problems of a card, prefixed with their JSON path
+type cardsValidator struct {
+	problems []string
+}
+
+func (v *cardsValidator) problem(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+// exactlyOne reports a problem unless exactly one of the named fields is set
+func (v *cardsValidator) exactlyOne(path string, fields map[string]bool) {
+	var set, names []string
+	for name, isSet := range fields {
+		names = append(names, name)
+		if isSet {
+			set = append(set, name)
+		}
+	}
+
+	if len(set) == 1 {
+		return
+	}
+
+	sort.Strings(names)
+	sort.Strings(set)
+	if len(set) == 0 {
+		v.problem(path, "should have one of %s", strings.Join(names, ", "))
+	} else {
+		v.problem(path, "should have only one of %s, got %s", strings.Join(names, ", "), strings.Join(set, ", "))
+	}
+}
+
+func (v *cardsValidator) onClick(path string, onClick *googlechat.OnClick, required bool) {
+	if onClick == nil {
+		if required {
+			v.problem(path, "onClick is required")
+		}
+		return
+	}
+
+	if onClick.OpenLink == nil || !isURL(onClick.OpenLink.URL) {
+		v.problem(path+".onClick.openLink.url", "should be a valid url")
+	}
+}
+
+// validateCards checks the structural rules of the legacy cards format
+func validateCards(cards []googlechat.Card) []string {
+	v := &cardsValidator{}
+
+	if len(cards) == 0 {
+		v.problem("cards", "at least one card is required")
+	}
+
+	for i, card := range cards {
+		cardPath := fmt.Sprintf("cards[%d]", i)
+
+		if card.Header != nil && card.Header.ImageURL != "" && !isWebURL(card.Header.ImageURL) {
+			v.problem(cardPath+".header.imageUrl", "should be a valid http(s) url")
+		}
+
+		if len(card.Sections) == 0 {
+			v.problem(cardPath, "at least one section is required")
+		}
+
+		for j, section := range card.Sections {
+			sectionPath := fmt.Sprintf("%s.sections[%d]", cardPath, j)
+
+			if len(section.Widgets) == 0 {
+				v.problem(sectionPath, "at least one widget is required")
+			}
+
+			for k, widget := range section.Widgets {
+				v.widget(fmt.Sprintf("%s.widgets[%d]", sectionPath, k), widget)
+			}
+		}
+	}
+
+	return v.problems
+}
+
+func (v *cardsValidator) widget(path string, widget *googlechat.Widget) {
+	if widget == nil {
+		v.problem(path, "should not be null")
+		return
+	}
+
+	v.exactlyOne(path, map[string]bool{
+		"textParagraph": widget.TextParagraph != nil,
+		"keyValue":      widget.KeyValue != nil,
+		"image":         widget.Image != nil,
+		"buttons":       len(widget.Buttons) > 0,
+	})
+
+	if widget.TextParagraph != nil && widget.TextParagraph.Text == "" {
+		v.problem(path+".textParagraph.text", "is required")
+	}
+
+	if kv := widget.KeyValue; kv != nil {
+		if kv.Content == "" {
+			v.problem(path+".keyValue.content", "is required")
+		}
+		if kv.Icon != "" && kv.IconURL != "" {
+			v.problem(path+".keyValue", "should have either an iconUrl or an icon, not both")
+		}
+		v.onClick(path+".keyValue", kv.OnClick, false)
+		if kv.Button != nil {
+			v.button(path+".keyValue.button", kv.Button)
+		}
+	}
+
+	if image := widget.Image; image != nil {
+		if !isWebURL(image.ImageURL) {
+			v.problem(path+".image.imageUrl", "should be a valid http(s) url")
+		}
+		v.onClick(path+".image", image.OnClick, false)
+	}
+
+	for i, button := range widget.Buttons {
+		v.button(fmt.Sprintf("%s.buttons[%d]", path, i), button)
+	}
+}
+
+func (v *cardsValidator) button(path string, button *googlechat.Button) {
+	if button == nil {
+		v.problem(path, "should not be null")
+		return
+	}
+
+	v.exactlyOne(path, map[string]bool{
+		"textButton":  button.TextButton != nil,
+		"imageButton": button.ImageButton != nil,
+	})
+
+	if button.TextButton != nil {
+		if button.TextButton.Text == "" {
+			v.problem(path+".textButton.text", "is required")
+		}
+		v.onClick(path+".textButton", button.TextButton.OnClick, true)
+	}
+
+	if button.ImageButton != nil {
+		if (button.ImageButton.Icon == "") == (button.ImageButton.IconURL == "") {
+			v.problem(path+".imageButton", "should have either an iconUrl or an icon")
+		}
+		v.onClick(path+".imageButton", button.ImageButton.OnClick, true)
+	}
+}
+
+// validateCardsV2 checks the structural rules of the cardsV2 format
+func validateCardsV2(cards []googlechat.CardWithID) []string {
+	v := &cardsValidator{}
+
+	if len(cards) == 0 {
+		v.problem("cardsV2", "at least one card is required")
+	}
+
+	for i, cardWithID := range cards {
+		cardPath := fmt.Sprintf("cardsV2[%d]", i)
+
+		card := cardWithID.Card
+		if card == nil {
+			v.problem(cardPath+".card", "is required")
+			continue
+		}
+		cardPath += ".card"
+
+		if len(card.Sections) == 0 {
+			v.problem(cardPath, "at least one section is required")
+		}
+
+		for j, section := range card.Sections {
+			sectionPath := fmt.Sprintf("%s.sections[%d]", cardPath, j)
+
+			if len(section.Widgets) == 0 {
+				v.problem(sectionPath, "at least one widget is required")
+			}
+
+			for k, widget := range section.Widgets {
+				v.widgetV2(fmt.Sprintf("%s.widgets[%d]", sectionPath, k), widget)
+			}
+		}
+	}
+
+	return v.problems
+}
+
+func (v *cardsValidator) widgetV2(path string, widget *googlechat.WidgetV2) {
+	if widget == nil {
+		v.problem(path, "should not be null")
+		return
+	}
+
+	v.exactlyOne(path, map[string]bool{
+		"textParagraph": widget.TextParagraph != nil,
+		"decoratedText": widget.DecoratedText != nil,
+		"image":         widget.Image != nil,
+		"buttonList":    widget.ButtonList != nil,
+		"divider":       widget.Divider != nil,
+		"grid":          widget.Grid != nil,
+		"columns":       widget.Columns != nil,
+	})
+
+	if widget.TextParagraph != nil && widget.TextParagraph.Text == "" {
+		v.problem(path+".textParagraph.text", "is required")
+	}
+
+	if text := widget.DecoratedText; text != nil {
+		if text.Text == "" {
+			v.problem(path+".decoratedText.text", "is required")
+		}
+		v.onClick(path+".decoratedText", text.OnClick, false)
+		if text.Button != nil {
+			v.buttonV2(path+".decoratedText.button", text.Button)
+		}
+	}
+
+	if image := widget.Image; image != nil {
+		if !isWebURL(image.ImageURL) {
+			v.problem(path+".image.imageUrl", "should be a valid http(s) url")
+		}
+		v.onClick(path+".image", image.OnClick, false)
+	}
+
+	if list := widget.ButtonList; list != nil {
+		if len(list.Buttons) == 0 {
+			v.problem(path+".buttonList.buttons", "at least one button is required")
+		}
+		for i, button := range list.Buttons {
+			v.buttonV2(fmt.Sprintf("%s.buttonList.buttons[%d]", path, i), button)
+		}
+	}
+
+	if grid := widget.Grid; grid != nil {
+		if len(grid.Items) == 0 {
+			v.problem(path+".grid.items", "at least one item is required")
+		}
+		v.onClick(path+".grid", grid.OnClick, false)
+	}
+
+	if columns := widget.Columns; columns != nil {
+		if len(columns.ColumnItems) == 0 || len(columns.ColumnItems) > 2 {
+			v.problem(path+".columns.columnItems", "should have one or two columns")
+		}
+		for i, column := range columns.ColumnItems {
+			for j, columnWidget := range column.Widgets {
+				v.widgetV2(fmt.Sprintf("%s.columns.columnItems[%d].widgets[%d]", path, i, j), columnWidget)
+			}
+		}
+	}
+}
+
+func (v *cardsValidator) buttonV2(path string, button *googlechat.ButtonV2) {
+	if button == nil {
+		v.problem(path, "should not be null")
+		return
+	}
+
+	if button.Text == "" && button.Icon == nil {
+		v.problem(path, "should have a text, an icon or both")
+	}
+	if button.Icon != nil && (button.Icon.KnownIcon == "") == (button.Icon.IconURL == "") {
+		v.problem(path+".icon", "should have either a knownIcon or an iconUrl")
+	}
+	v.onClick(path, button.OnClick, true)
+}
+
+// applyRawCardsHeader replaces the header of the first card with the header from the inputs, if any
+func applyRawCardsHeader(msg *googlechat.Message, header *googlechat.Header) {
+	if header == nil {
+		return
+	}
+
+	if len(msg.Cards) > 0 {
+		msg.Cards[0].Header = header
+	}
+	if len(msg.CardsV2) > 0 && msg.CardsV2[0].Card != nil {
+		msg.CardsV2[0].Card.Header = googlechat.HeaderToV2(header)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_ParseRawCards(t *testing.T) {
+	openLink := &googlechat.OnClick{OpenLink: &googlechat.OpenLink{URL: "https://example.org"}}
+	card := googlechat.Card{
+		Header: &googlechat.Header{Title: "Build", ImageURL: "https://example.org/logo.png", ImageStyle: "circular"},
+		Sections: []googlechat.Section{{
+			Widgets: []*googlechat.Widget{
+				{TextParagraph: &googlechat.TextParagraph{Text: "Passed"}},
+				{Buttons: []*googlechat.Button{{TextButton: &googlechat.TextButton{Text: "Open", OnClick: openLink}}}},
+			},
+		}},
+	}
+	cardV2 := googlechat.CardWithID{
+		CardID: "build",
+		Card: &googlechat.CardV2{
+			Sections: []googlechat.SectionV2{{
+				Widgets: []*googlechat.WidgetV2{
+					{DecoratedText: &googlechat.DecoratedText{TopLabel: "Branch", Text: "main"}},
+					{ButtonList: &googlechat.ButtonList{Buttons: []*googlechat.ButtonV2{{Text: "Open", OnClick: openLink}}}},
+				},
+			}},
+		},
+	}
+
+	cardJSON := `{
+		"header": {"title": "Build", "imageUrl": "https://example.org/logo.png", "imageStyle": "AVATAR"},
+		"sections": [{"widgets": [
+			{"textParagraph": {"text": "Passed"}},
+			{"buttons": [{"textButton": {"text": "Open", "onClick": {"openLink": {"url": "https://example.org"}}}}]}
+		]}]
+	}`
+	cardV2JSON := `{"cardId": "build", "card": {"sections": [{"widgets": [
+		{"decoratedText": {"topLabel": "Branch", "text": "main"}},
+		{"buttonList": {"buttons": [{"text": "Open", "onClick": {"openLink": {"url": "https://example.org"}}}]}}
+	]}]}}`
+
+	tests := []struct {
+		name        string
+		input       string
+		cardVersion string
+		cards       []googlechat.Card
+		cardsV2     []googlechat.CardWithID
+		err         string
+	}{
+		{
+			name:  "Cards object",
+			input: `{"cards": [` + cardJSON + `]}`,
+			cards: []googlechat.Card{card},
+		},
+		{
+			name:    "CardsV2 object",
+			input:   `{"cardsV2": [` + cardV2JSON + `]}`,
+			cardsV2: []googlechat.CardWithID{cardV2},
+		},
+		{
+			name:  "Array of cards",
+			input: `[` + cardJSON + `]`,
+			cards: []googlechat.Card{card},
+		},
+		{
+			name:        "Array of cardsV2",
+			input:       `[` + cardV2JSON + `]`,
+			cardVersion: cardsV2,
+			cardsV2:     []googlechat.CardWithID{cardV2},
+		},
+		{
+			name:  "Unknown field",
+			input: `[{"sections": [{"widgets": [{"textParagraph": {"txt": "Passed"}}]}]}]`,
+			err:   `Could not parse cards: json: unknown field "txt"`,
+		},
+		{
+			name:  "Unknown image style",
+			input: `[{"header": {"imageStyle": "ROUND"}, "sections": [{"widgets": [{"textParagraph": {"text": "Passed"}}]}]}]`,
+			err:   "Could not parse cards: unknown imageStyle ROUND, expected IMAGE or AVATAR",
+		},
+		{
+			name:  "Both formats",
+			input: `{"cards": [` + cardJSON + `], "cardsV2": [` + cardV2JSON + `]}`,
+			err:   "Could not parse cards: either cards or cardsV2 should be provided, not both",
+		},
+		{
+			name: "Invalid cards",
+			input: `[{"sections": [
+				{"widgets": [{"textParagraph": {"text": "Passed"}, "image": {"imageUrl": "https://example.org/a.png"}}]},
+				{"widgets": []},
+				{"widgets": [{"keyValue": {"topLabel": "Branch"}}, {"buttons": [{"textButton": {"text": "Open"}}]}]}
+			]}, {}]`,
+			err: "Invalid cards:\n" +
+				"- cards[0].sections[0].widgets[0]: should have only one of buttons, image, keyValue, textParagraph, got image, textParagraph\n" +
+				"- cards[0].sections[1]: at least one widget is required\n" +
+				"- cards[0].sections[2].widgets[0].keyValue.content: is required\n" +
+				"- cards[0].sections[2].widgets[1].buttons[0].textButton: onClick is required\n" +
+				"- cards[1]: at least one section is required",
+		},
+		{
+			name: "Invalid cardsV2",
+			input: `{"cardsV2": [{"card": {"sections": [{"widgets": [
+				{},
+				{"buttonList": {"buttons": [{"onClick": {"openLink": {"url": "not a url"}}}]}},
+				{"columns": {"columnItems": [{}, {}, {}]}}
+			]}]}}, {"cardId": "empty"}]}`,
+			err: "Invalid cards:\n" +
+				"- cardsV2[0].card.sections[0].widgets[0]: should have one of buttonList, columns, decoratedText, divider, grid, image, textParagraph\n" +
+				"- cardsV2[0].card.sections[0].widgets[1].buttonList.buttons[0]: should have a text, an icon or both\n" +
+				"- cardsV2[0].card.sections[0].widgets[1].buttonList.buttons[0].onClick.openLink.url: should be a valid url\n" +
+				"- cardsV2[0].card.sections[0].widgets[2].columns.columnItems: should have one or two columns\n" +
+				"- cardsV2[1].card: is required",
+		},
+		{
+			name:  "Empty",
+			input: `[]`,
+			err:   "Invalid cards:\n- cards: at least one card is required",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cards, cardsV2, err := ParseRawCards(tc.input, tc.cardVersion)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error:\nexpected: %s\ngot:      %s", tc.err, err)
+				return
+			}
+
+			if !cmp.Equal(cards, tc.cards) {
+				t.Errorf("Cards are not correct: %s", cmp.Diff(tc.cards, cards))
+			}
+			if !cmp.Equal(cardsV2, tc.cardsV2) {
+				t.Errorf("CardsV2 are not correct: %s", cmp.Diff(tc.cardsV2, cardsV2))
+			}
+		})
+	}
+}
+
+func Test_newMessage_rawCards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cards")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cards.json")
+	if err := ioutil.WriteFile(path, []byte(`[{"sections": [{"widgets": [{"textParagraph": {"text": "From file"}}]}]}]`), 0600); err != nil {
+		t.Fatalf("Failed to write cards file: %s", err)
+	}
+
+	build := testBuildContext(true)
+	msg, err := newMessage(Config{
+		Title:     "Build passed",
+		Text:      "ignored",
+		CardsFile: path,
+	}, build)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := googlechat.Message{
+		Text: "Build passed",
+		Cards: []googlechat.Card{{
+			Header:   &googlechat.Header{Title: "Build passed"},
+			Sections: []googlechat.Section{{Widgets: []*googlechat.Widget{{TextParagraph: &googlechat.TextParagraph{Text: "From file"}}}}},
+		}},
+	}
+	if !cmp.Equal(msg, expected) {
+		t.Errorf("Message is not correct: %s", cmp.Diff(expected, msg))
+	}
+}
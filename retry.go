@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// sleep pauses the current goroutine. Replaced in tests so retries don't slow them down.
+var sleep = time.Sleep
+
+// now returns the current time. Replaced in tests to control the total retry time.
+var now = time.Now
+
+// maxRetryDelay caps the exponential backoff of a single wait
+const maxRetryDelay = 10 * time.Minute
+
+// RetryPolicy defines how often and how long failed deliveries are retried
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one
+	MaxAttempts int
+	// BaseDelay is the wait before the second attempt, doubled for every following attempt
+	BaseDelay time.Duration
+	// Jitter randomizes the wait between 50% and 100% of the computed delay
+	Jitter bool
+	// MaxTotalTime is the maximum time spent on all attempts, zero means no limit
+	MaxTotalTime time.Duration
+}
+
+// newRetryPolicy creates a RetryPolicy from the step configuration
+func newRetryPolicy(c Config) RetryPolicy {
+	maxAttempts := c.RetryMaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	return RetryPolicy{
+		MaxAttempts:  maxAttempts,
+		BaseDelay:    time.Duration(c.RetryBaseDelay * float64(time.Second)),
+		Jitter:       c.RetryJitter,
+		MaxTotalTime: time.Duration(c.RetryMaxTime * float64(time.Second)),
+	}
+}
+
+// delay returns the time to wait after the given (1 based) failed attempt
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+
+	if p.Jitter && d > 0 {
+		half := int64(d / 2)
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+
+	return d
+}
+
+// withRetry calls attempt until it succeeds, returns a permanent error or the policy is exhausted.
+// The label identifies the target in the logs.
+func withRetry(label string, policy RetryPolicy, attempt func() error) error {
+	start := now()
+
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil {
+			log.Printf("%s: attempt %d/%d delivered", label, n, policy.MaxAttempts)
+			return nil
+		}
+
+		deliveryErr, ok := err.(*googlechat.DeliveryError)
+		if !ok || !deliveryErr.Temporary {
+			log.Warnf("%s: attempt %d/%d failed permanently: %s", label, n, policy.MaxAttempts, err)
+			return err
+		}
+
+		if n >= policy.MaxAttempts {
+			log.Warnf("%s: attempt %d/%d failed: %s", label, n, policy.MaxAttempts, err)
+			return fmt.Errorf("giving up after %d attempts: %s", n, err)
+		}
+
+		wait := policy.delay(n)
+		if deliveryErr.RetryAfter > wait {
+			wait = deliveryErr.RetryAfter
+		}
+
+		if policy.MaxTotalTime > 0 && now().Sub(start)+wait > policy.MaxTotalTime {
+			log.Warnf("%s: attempt %d/%d failed: %s", label, n, policy.MaxAttempts, err)
+			return fmt.Errorf("giving up after %d attempts, retrying in %s would exceed the maximum retry time of %s: %s", n, wait, policy.MaxTotalTime, err)
+		}
+
+		log.Warnf("%s: attempt %d/%d failed: %s, retrying in %s", label, n, policy.MaxAttempts, err, wait)
+		sleep(wait)
+	}
+}
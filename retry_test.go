@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/bitrise-io/go-steputils/stepconf"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_RetryPolicy_delay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+	}
+
+	tests := []struct {
+		attempt int
+		output  time.Duration
+	}{
+		{attempt: 1, output: time.Second},
+		{attempt: 2, output: 2 * time.Second},
+		{attempt: 3, output: 4 * time.Second},
+		{attempt: 20, output: maxRetryDelay},
+	}
+
+	for _, tc := range tests {
+		if delay := policy.delay(tc.attempt); delay != tc.output {
+			t.Errorf("Delay after attempt %d is not correct: expected %s, got %s", tc.attempt, tc.output, delay)
+		}
+	}
+
+	policy.Jitter = true
+	for i := 0; i < 100; i++ {
+		if delay := policy.delay(3); delay < 2*time.Second || delay > 4*time.Second {
+			t.Errorf("Delay with jitter is out of range: %s", delay)
+		}
+	}
+}
+
+func Test_postMessage_retry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		header   http.Header
+		config   Config
+		requests int
+		waits    []time.Duration
+		err      string
+	}{
+		{
+			name:     "Success on first attempt",
+			statuses: []int{http.StatusOK},
+			config:   Config{RetryMaxAttempts: 3, RetryBaseDelay: 1},
+			requests: 1,
+		},
+		{
+			name:     "Retry server errors",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK},
+			config:   Config{RetryMaxAttempts: 3, RetryBaseDelay: 1},
+			requests: 3,
+			waits:    []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:     "Honor Retry-After",
+			statuses: []int{http.StatusTooManyRequests, http.StatusOK},
+			header:   http.Header{"Retry-After": []string{"7"}},
+			config:   Config{RetryMaxAttempts: 3, RetryBaseDelay: 1},
+			requests: 2,
+			waits:    []time.Duration{7 * time.Second},
+		},
+		{
+			name:     "Stop on permanent errors",
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			config:   Config{RetryMaxAttempts: 3, RetryBaseDelay: 1},
+			requests: 1,
+			err:      "server error: 400 Bad Request, response: failed",
+		},
+		{
+			name:     "Give up after max attempts",
+			statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			config:   Config{RetryMaxAttempts: 2, RetryBaseDelay: 1},
+			requests: 2,
+			waits:    []time.Duration{time.Second},
+			err:      "giving up after 2 attempts: server error: 502 Bad Gateway, response: failed",
+		},
+		{
+			name:     "Give up when exceeding max time",
+			statuses: []int{http.StatusTooManyRequests, http.StatusOK},
+			header:   http.Header{"Retry-After": []string{"120"}},
+			config:   Config{RetryMaxAttempts: 3, RetryBaseDelay: 1, RetryMaxTime: 60},
+			requests: 1,
+			err:      "giving up after 1 attempts, retrying in 2m0s would exceed the maximum retry time of 1m0s: server error: 429 Too Many Requests, response: failed",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var waits []time.Duration
+			sleep = func(d time.Duration) { waits = append(waits, d) }
+			defer func() { sleep = time.Sleep }()
+
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tc.statuses[requests]
+				requests++
+
+				for key, values := range tc.header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(status)
+				if status != http.StatusOK {
+					w.Write([]byte("failed"))
+				}
+			}))
+			defer server.Close()
+
+			tc.config.WebhookURL = stepconf.Secret(server.URL)
+
+			_, err := postMessage(tc.config, googlechat.Message{Text: "text"})
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+			}
+
+			if requests != tc.requests {
+				t.Errorf("Unexpected number of requests: expected %d, got %d", tc.requests, requests)
+			}
+
+			if !cmp.Equal(waits, tc.waits) {
+				t.Errorf("Waits are not correct: expected %v, got %v", tc.waits, waits)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+)
+
+// Values of the when field of a SectionInput and a CardInput
+const (
+	whenAlways  = "always"
+	whenSuccess = "success"
+	whenFailure = "failure"
+)
+
+// SectionInput defines the input format of a section of the sections input
+type SectionInput struct {
+	Header string `json:"header,omitempty"`
+	// When the section is shown: "always" (default), "success" or "failure"
+	When string `json:"when,omitempty"`
+	// Collapsible and UncollapsibleWidgetsCount are only supported by cardsV2
+	Collapsible               bool          `json:"collapsible,omitempty"`
+	UncollapsibleWidgetsCount int           `json:"uncollapsibleWidgetsCount,omitempty"`
+	Widgets                   []WidgetInput `json:"widgets"`
+}
+
+// WidgetInput defines the input format of a widget of a SectionInput. Only one field should be set
+type WidgetInput struct {
+	Text     string         `json:"text,omitempty"`
+	KeyValue *KeyValueInput `json:"keyValue,omitempty"`
+	Image    *ImageInput    `json:"image,omitempty"`
+	Buttons  []ButtonInput  `json:"buttons,omitempty"`
+}
+
+// ImageInput defines the input format of an Image widget
+type ImageInput struct {
+	ImageURL string `json:"imageUrl,omitempty"`
+	OnClick  string `json:"onClick,omitempty"`
+	AltText  string `json:"altText,omitempty"`
+}
+
+// ParseSections parses an array of sections written as JSON or YAML. Sections which should not be shown for the build status are omitted
+func ParseSections(raw string, build BuildContext) (sections []googlechat.Section, err error) {
+	if raw == "" {
+		return
+	}
+
+	var sectionInputs []SectionInput
+	if err = unmarshalJSONOrYAML(raw, &sectionInputs); err != nil {
+		err = fmt.Errorf("Could not parse sections: %s", err)
+		return
+	}
+
+	return parseSectionInputs(sectionInputs, "sections", build)
+}
+
+// parseSectionInputs converts the section inputs to sections. path prefixes the errors, e.g. sections[0]
+func parseSectionInputs(sectionInputs []SectionInput, path string, build BuildContext) (sections []googlechat.Section, err error) {
+	for i, input := range sectionInputs {
+		var shown bool
+		shown, err = isShown(input.When, build)
+		if err != nil {
+			err = fmt.Errorf("%s[%d]: %s", path, i, err)
+			return
+		}
+		if !shown {
+			continue
+		}
+
+		if len(input.Widgets) == 0 {
+			err = fmt.Errorf("%s[%d]: a section should have at least one widget", path, i)
+			return
+		}
+
+		section := googlechat.Section{
+			Header:                    input.Header,
+			Collapsible:               input.Collapsible,
+			UncollapsibleWidgetsCount: input.UncollapsibleWidgetsCount,
+		}
+
+		for j, widgetInput := range input.Widgets {
+			var widget *googlechat.Widget
+			widget, err = parseWidgetInput(widgetInput)
+			if err != nil {
+				err = fmt.Errorf("%s[%d].widgets[%d]: %s", path, i, j, err)
+				return
+			}
+
+			section.Widgets = append(section.Widgets, widget)
+		}
+
+		sections = append(sections, section)
+	}
+
+	return
+}
+
+// isShown tells if an element with the given when value is shown for the build status
+func isShown(when string, build BuildContext) (bool, error) {
+	switch when {
+	case "", whenAlways:
+		return true, nil
+	case whenSuccess:
+		return build.Succeeded(), nil
+	case whenFailure:
+		return !build.Succeeded(), nil
+	default:
+		return false, fmt.Errorf("unknown when value %s, expected always, success or failure", when)
+	}
+}
+
+func parseWidgetInput(input WidgetInput) (widget *googlechat.Widget, err error) {
+	set := 0
+	for _, isSet := range []bool{input.Text != "", input.KeyValue != nil, input.Image != nil, len(input.Buttons) > 0} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		err = fmt.Errorf("a widget should have exactly one of text, keyValue, image or buttons")
+		return
+	}
+
+	switch {
+	case input.Text != "":
+		widget = &googlechat.Widget{
+			TextParagraph: &googlechat.TextParagraph{
+				Text: input.Text,
+			},
+		}
+
+	case input.KeyValue != nil:
+		widget, err = parseKeyValue(*input.KeyValue)
+
+	case input.Image != nil:
+		widget, err = parseImage(input.Image.ImageURL, input.Image.OnClick, input.Image.AltText)
+
+	default:
+		var buttons []*googlechat.Button
+		for k, buttonInput := range input.Buttons {
+			var button *googlechat.Button
+			button, err = parseButtonInput(buttonInput, fmt.Sprintf("Button %d", k+1))
+			if err != nil {
+				return
+			}
+			buttons = append(buttons, button)
+		}
+
+		widget = &googlechat.Widget{
+			Buttons: buttons,
+		}
+	}
+
+	return
+}
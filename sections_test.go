@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_ParseSections(t *testing.T) {
+	input := `[
+		{"header": "Status", "widgets": [{"text": "<b>Passed</b>"}]},
+		{"header": "Failures", "when": "failure", "widgets": [{"keyValue": {"topLabel": "Failed tests", "content": "3"}}]},
+		{"header": "Artifacts", "collapsible": true, "uncollapsibleWidgetsCount": 1, "widgets": [
+			{"image": {"imageUrl": "https://example.com/coverage.svg", "altText": "Coverage"}},
+			{"buttons": [{"text": "Download", "onClick": "https://example.org/app.apk"}, {"icon": "STAR", "onClick": "https://example.org"}]}
+		]}
+	]`
+
+	downloadButton := &googlechat.Button{
+		TextButton: &googlechat.TextButton{
+			Text:    "Download",
+			OnClick: &googlechat.OnClick{OpenLink: &googlechat.OpenLink{URL: "https://example.org/app.apk"}},
+		},
+	}
+	starButton := &googlechat.Button{
+		ImageButton: &googlechat.ImageButton{
+			Icon:    "STAR",
+			OnClick: &googlechat.OnClick{OpenLink: &googlechat.OpenLink{URL: "https://example.org"}},
+		},
+	}
+	status := googlechat.Section{
+		Header:  "Status",
+		Widgets: []*googlechat.Widget{{TextParagraph: &googlechat.TextParagraph{Text: "<b>Passed</b>"}}},
+	}
+	failures := googlechat.Section{
+		Header: "Failures",
+		Widgets: []*googlechat.Widget{{KeyValue: &googlechat.KeyValue{
+			TopLabel:         "Failed tests",
+			Content:          "3",
+			ContentMultiline: "false",
+		}}},
+	}
+	artifacts := googlechat.Section{
+		Header:                    "Artifacts",
+		Collapsible:               true,
+		UncollapsibleWidgetsCount: 1,
+		Widgets: []*googlechat.Widget{
+			{Image: &googlechat.Image{ImageURL: "https://example.com/coverage.svg", AltText: "Coverage"}},
+			{Buttons: []*googlechat.Button{downloadButton, starButton}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		success bool
+		input   string
+		output  []googlechat.Section
+		err     string
+	}{
+		{
+			name:    "Success omits failure sections",
+			success: true,
+			input:   input,
+			output:  []googlechat.Section{status, artifacts},
+		},
+		{
+			name:    "Failure",
+			success: false,
+			input:   input,
+			output:  []googlechat.Section{status, failures, artifacts},
+		},
+		{
+			name:    "Empty",
+			success: true,
+			input:   "",
+			output:  nil,
+		},
+		{
+			name:    "YAML",
+			success: false,
+			input: `
+- header: Status
+  widgets:
+  - text: <b>Passed</b>
+- header: Failures
+  when: failure
+  widgets:
+  - keyValue: {topLabel: Failed tests, content: "3"}
+- header: Artifacts
+  collapsible: true
+  uncollapsibleWidgetsCount: 1
+  widgets:
+  - image: {imageUrl: "https://example.com/coverage.svg", altText: Coverage}
+  - buttons:
+    - {text: Download, onClick: "https://example.org/app.apk"}
+    - {icon: STAR, onClick: "https://example.org"}
+`,
+			output: []googlechat.Section{status, failures, artifacts},
+		},
+		{
+			name:    "Flow style YAML",
+			success: true,
+			input:   `[{header: Status, widgets: [{text: <b>Passed</b>}]}]`,
+			output:  []googlechat.Section{status},
+		},
+		{
+			name:    "Invalid YAML",
+			success: true,
+			input:   "- header: Status\n widgets: [",
+			err:     "Could not parse sections: yaml: line 1: did not find expected '-' indicator",
+		},
+		{
+			name:    "Invalid JSON",
+			success: true,
+			input:   `{"header": "Status"}`,
+			err:     "Could not parse sections: json: cannot unmarshal object into Go value of type []main.SectionInput",
+		},
+		{
+			name:    "Unknown when",
+			success: true,
+			input:   `[{"when": "sometimes", "widgets": [{"text": "text"}]}]`,
+			err:     "sections[0]: unknown when value sometimes, expected always, success or failure",
+		},
+		{
+			name:    "Section without widgets",
+			success: true,
+			input:   `[{"header": "Status"}]`,
+			err:     "sections[0]: a section should have at least one widget",
+		},
+		{
+			name:    "Widget with multiple elements",
+			success: true,
+			input:   `[{"widgets": [{"text": "text"}, {"text": "text", "image": {"imageUrl": "https://example.com/a.png"}}]}]`,
+			err:     "sections[0].widgets[1]: a widget should have exactly one of text, keyValue, image or buttons",
+		},
+		{
+			name:    "Invalid button",
+			success: true,
+			input:   `[{"widgets": [{"buttons": [{"text": "Download"}]}]}]`,
+			err:     "sections[0].widgets[0]: Button 1 should have an onClick value",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			build := testBuildContext(tc.success)
+
+			sections, err := ParseSections(tc.input, build)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if tc.err == "" && !cmp.Equal(sections, tc.output) {
+				t.Errorf("Returned sections are not correct:\n%s", cmp.Diff(tc.output, sections))
+			}
+		})
+	}
+}
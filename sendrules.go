@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Values of the send_when input
+const (
+	sendAlways       = "always"
+	sendOnFailure    = "failure"
+	sendOnChange     = "status_change"
+	sendNotOnSuccess = "not_success"
+)
+
+// Values of the trigger_filter input
+const (
+	triggerAny         = "any"
+	triggerPullRequest = "pull_request"
+	triggerPush        = "push"
+	triggerTag         = "tag"
+)
+
+// sendRule decides whether the message is sent for a build
+type sendRule struct {
+	// Input is the name of the input configuring the rule, logged if the rule suppresses the message
+	Input string
+	// Suppress returns why the message is not sent for the build, or an empty string if the rule allows it
+	Suppress func(build BuildContext) string
+}
+
+// triggerType returns how the build was triggered: pull_request, tag or push
+func triggerType(build BuildContext) string {
+	switch {
+	case build.PullRequest != "":
+		return triggerPullRequest
+	case build.Tag != "":
+		return triggerTag
+	default:
+		return triggerPush
+	}
+}
+
+// parseList splits an input on newlines and commas, omitting empty values
+func parseList(s string) []string {
+	var values []string
+	for _, line := range strings.Split(s, "\n") {
+		for _, value := range strings.Split(line, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// parseBranchPatterns parses the glob patterns of a branch filter input, e.g. release/*
+func parseBranchPatterns(input, s string) ([]string, error) {
+	patterns := parseList(s)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid pattern %q in %s: %s", pattern, input, err)
+		}
+	}
+	return patterns, nil
+}
+
+// matchBranch returns the first pattern matching the branch, or an empty string
+func matchBranch(patterns []string, branch string) string {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// sendRules returns the rules of the inputs, in the order they are checked
+func sendRules(conf Config) ([]sendRule, error) {
+	include, err := parseBranchPatterns("branch_include", conf.BranchInclude)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := parseBranchPatterns("branch_exclude", conf.BranchExclude)
+	if err != nil {
+		return nil, err
+	}
+	notifyOn, err := parseNotifyOn(conf.NotifyOn)
+	if err != nil {
+		return nil, err
+	}
+	workflows := parseList(conf.WorkflowAllowlist)
+
+	return []sendRule{
+		{
+			Input: "workflow_allowlist",
+			Suppress: func(build BuildContext) string {
+				if len(workflows) == 0 || containsString(workflows, build.Workflow) {
+					return ""
+				}
+				return fmt.Sprintf("the workflow %q is not one of %s", build.Workflow, strings.Join(workflows, ", "))
+			},
+		},
+		{
+			Input: "trigger_filter",
+			Suppress: func(build BuildContext) string {
+				trigger := triggerType(build)
+				if conf.TriggerFilter == "" || conf.TriggerFilter == triggerAny || conf.TriggerFilter == trigger {
+					return ""
+				}
+				return fmt.Sprintf("the build was triggered by a %s, messages are only sent for a %s", trigger, conf.TriggerFilter)
+			},
+		},
+		{
+			// Builds without a branch, e.g. tag builds, are not filtered by branch
+			Input: "branch_include",
+			Suppress: func(build BuildContext) string {
+				if len(include) == 0 || build.Branch == "" || matchBranch(include, build.Branch) != "" {
+					return ""
+				}
+				return fmt.Sprintf("the branch %q doesn't match any of %s", build.Branch, strings.Join(include, ", "))
+			},
+		},
+		{
+			Input: "branch_exclude",
+			Suppress: func(build BuildContext) string {
+				if pattern := matchBranch(exclude, build.Branch); build.Branch != "" && pattern != "" {
+					return fmt.Sprintf("the branch %q matches %s", build.Branch, pattern)
+				}
+				return ""
+			},
+		},
+		{
+			Input: "send_when",
+			Suppress: func(build BuildContext) string {
+				switch conf.SendWhen {
+				case sendOnFailure:
+					if build.Status != statusFailed {
+						return fmt.Sprintf("the build status is %s, messages are only sent for failed builds", build.Status)
+					}
+				case sendOnChange:
+					if build.PreviousStatus == build.Status {
+						return fmt.Sprintf("the build status %s is the same as the status of the previous build", build.Status)
+					}
+				case sendNotOnSuccess:
+					if build.Succeeded() {
+						return "the build succeeded, messages are not sent for successful builds"
+					}
+				}
+				return ""
+			},
+		},
+		{
+			Input: "notify_on",
+			Suppress: func(build BuildContext) string {
+				if notifyOn[build.State] {
+					return ""
+				}
+				return fmt.Sprintf("the build state %s is not one of %s", build.State, strings.TrimSpace(conf.NotifyOn))
+			},
+		},
+	}, nil
+}
+
+// checkSendRules returns the input of the first rule suppressing the message for the build and why, or empty strings if it is sent
+func checkSendRules(rules []sendRule, build BuildContext) (string, string) {
+	for _, rule := range rules {
+		if reason := rule.Suppress(build); reason != "" {
+			return rule.Input, reason
+		}
+	}
+	return "", ""
+}
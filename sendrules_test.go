@@ -0,0 +1,144 @@
+package main
+
+import "testing"
+
+func Test_checkSendRules(t *testing.T) {
+	success := BuildContext{Status: statusSuccess, State: stateSuccess, Workflow: "primary", Branch: "main", PreviousStatus: statusSuccess}
+	fixed := BuildContext{Status: statusSuccess, State: stateFixed, Workflow: "primary", Branch: "main", PreviousStatus: statusFailed}
+	failed := BuildContext{Status: statusFailed, State: stateFailure, Workflow: "primary", Branch: "feature/chat"}
+	aborted := BuildContext{Status: statusAborted, State: stateAborted, Workflow: "primary", Branch: "main"}
+	pullRequest := BuildContext{Status: statusSuccess, State: stateSuccess, Workflow: "pr", Branch: "feature/chat", PullRequest: "42"}
+	tag := BuildContext{Status: statusSuccess, State: stateSuccess, Workflow: "deploy", Tag: "v1.0.0"}
+
+	tests := []struct {
+		name   string
+		config Config
+		build  BuildContext
+		input  string
+		reason string
+	}{
+		{name: "No rules", config: Config{}, build: success},
+		{name: "Always", config: Config{SendWhen: sendAlways}, build: success},
+		{
+			name:   "Only failure suppresses success",
+			config: Config{SendWhen: sendOnFailure},
+			build:  success,
+			input:  "send_when",
+			reason: "the build status is success, messages are only sent for failed builds",
+		},
+		{
+			name:   "Only failure suppresses aborted",
+			config: Config{SendWhen: sendOnFailure},
+			build:  aborted,
+			input:  "send_when",
+			reason: "the build status is aborted, messages are only sent for failed builds",
+		},
+		{name: "Only failure sends failure", config: Config{SendWhen: sendOnFailure}, build: failed},
+		{
+			name:   "Status change suppresses the same status",
+			config: Config{SendWhen: sendOnChange},
+			build:  success,
+			input:  "send_when",
+			reason: "the build status success is the same as the status of the previous build",
+		},
+		{name: "Status change sends recovery", config: Config{SendWhen: sendOnChange}, build: fixed},
+		{name: "Status change sends unknown previous build", config: Config{SendWhen: sendOnChange}, build: failed},
+		{
+			name:   "Never on success suppresses recovery",
+			config: Config{SendWhen: sendNotOnSuccess},
+			build:  fixed,
+			input:  "send_when",
+			reason: "the build succeeded, messages are not sent for successful builds",
+		},
+		{name: "Never on success sends aborted", config: Config{SendWhen: sendNotOnSuccess}, build: aborted},
+		{name: "Branch included", config: Config{BranchInclude: "main\nrelease/*"}, build: success},
+		{
+			name:   "Branch not included",
+			config: Config{BranchInclude: "main, release/*"},
+			build:  failed,
+			input:  "branch_include",
+			reason: `the branch "feature/chat" doesn't match any of main, release/*`,
+		},
+		{name: "Tag build without branch is not filtered", config: Config{BranchInclude: "main"}, build: tag},
+		{
+			name:   "Branch excluded",
+			config: Config{BranchExclude: "feature/*"},
+			build:  failed,
+			input:  "branch_exclude",
+			reason: `the branch "feature/chat" matches feature/*`,
+		},
+		{name: "Pull request only", config: Config{TriggerFilter: triggerPullRequest}, build: pullRequest},
+		{
+			name:   "Pull request only suppresses push",
+			config: Config{TriggerFilter: triggerPullRequest},
+			build:  success,
+			input:  "trigger_filter",
+			reason: "the build was triggered by a push, messages are only sent for a pull_request",
+		},
+		{
+			name:   "Push only suppresses tag",
+			config: Config{TriggerFilter: triggerPush},
+			build:  tag,
+			input:  "trigger_filter",
+			reason: "the build was triggered by a tag, messages are only sent for a push",
+		},
+		{name: "Tag only", config: Config{TriggerFilter: triggerTag}, build: tag},
+		{name: "Workflow allowed", config: Config{WorkflowAllowlist: "primary, deploy"}, build: tag},
+		{
+			name:   "Workflow not allowed",
+			config: Config{WorkflowAllowlist: "primary, deploy"},
+			build:  pullRequest,
+			input:  "workflow_allowlist",
+			reason: `the workflow "pr" is not one of primary, deploy`,
+		},
+		{
+			name:   "Build state not notified",
+			config: Config{NotifyOn: "failure,fixed"},
+			build:  success,
+			input:  "notify_on",
+			reason: "the build state success is not one of failure,fixed",
+		},
+		{
+			name:   "First rule decides",
+			config: Config{WorkflowAllowlist: "deploy", SendWhen: sendOnFailure},
+			build:  success,
+			input:  "workflow_allowlist",
+			reason: `the workflow "primary" is not one of deploy`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := sendRules(tc.config)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			input, reason := checkSendRules(rules, tc.build)
+			if input != tc.input || reason != tc.reason {
+				t.Errorf("Returned rule is not correct: expected %s: %s, got %s: %s", tc.input, tc.reason, input, reason)
+			}
+		})
+	}
+}
+
+func Test_sendRules_invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{name: "Invalid include pattern", config: Config{BranchInclude: "release/[0-9"}, err: `Invalid pattern "release/[0-9" in branch_include: syntax error in pattern`},
+		{name: "Invalid exclude pattern", config: Config{BranchExclude: "main, [a-"}, err: `Invalid pattern "[a-" in branch_exclude: syntax error in pattern`},
+		{name: "Invalid state", config: Config{NotifyOn: "recovered"}, err: `Unknown state "recovered" in notify_on, expected one of success, failure, fixed, still_failing, aborted`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := sendRules(tc.config); err == nil || err.Error() != tc.err {
+				t.Errorf("Unexpected error: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Values of the mode input
+const (
+	sendMode   = "send"
+	resendMode = "resend"
+)
+
+// SpoolEntry is a message which could not be delivered, saved to be sent again later.
+// The webhook url contains a secret, so only the label of the target is saved.
+// The url is looked up in the webhook_url input when the message is resent.
+type SpoolEntry struct {
+	// Target is the label of the webhook the message was sent to
+	Target      string          `json:"target"`
+	ThreadKey   string          `json:"threadKey,omitempty"`
+	ReplyOption string          `json:"replyOption,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Error       string          `json:"error,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// isPermanent returns true if sending the message again won't succeed, e.g. because it was rejected
+func isPermanent(err error) bool {
+	deliveryErr, ok := err.(*googlechat.DeliveryError)
+	return ok && !deliveryErr.Temporary
+}
+
+// spoolMessage saves a message which could not be delivered to the target in the spool directory.
+// Files are named after their creation time, so they are resent in order.
+func spoolMessage(dir string, target Target, thread *googlechat.Thread, replyOption string, payload []byte, cause error) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create spool directory %s: %s", dir, err)
+	}
+
+	entry := SpoolEntry{
+		Target:      target.Label,
+		ReplyOption: replyOption,
+		CreatedAt:   now(),
+		Payload:     payload,
+	}
+	if thread != nil {
+		entry.ThreadKey = thread.ThreadKey
+	}
+	if cause != nil {
+		entry.Error = cause.Error()
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%020d-%s.json", entry.CreatedAt.UnixNano(), unsafeFileNameChars.ReplaceAllString(target.Label, "_"))
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		return "", fmt.Errorf("failed to write spool entry %s: %s", path, err)
+	}
+
+	return path, nil
+}
+
+// spoolFailures saves the messages which could not be delivered because of a temporary failure
+func spoolFailures(conf Config, msg googlechat.Message, payload []byte, results []TargetResult) {
+	if conf.SpoolDir == "" {
+		return
+	}
+
+	for _, result := range results {
+		if result.Err == nil || isPermanent(result.Err) {
+			continue
+		}
+
+		path, err := spoolMessage(conf.SpoolDir, result.Target, msg.Thread, conf.ThreadReplyOption, payload, result.Err)
+		if err != nil {
+			log.Warnf("%s: failed to spool the message: %s", result.Target.Label, err)
+			continue
+		}
+
+		log.Warnf("%s: message saved to %s, resend it using the resend mode", result.Target.Label, path)
+	}
+}
+
+// readSpool returns the paths of the spool entries in the directory, oldest first
+func readSpool(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// resendSpool sends every message in the spool directory again, deleting the ones which are delivered.
+// If a message can't be delivered, the following messages of the same thread are kept to preserve their order.
+func resendSpool(conf Config) error {
+	if conf.SpoolDir == "" {
+		return fmt.Errorf("SpoolDir is empty. You need to provide one to resend messages")
+	}
+
+	targets, err := parseTargets(string(conf.WebhookURL))
+	if err != nil {
+		return err
+	}
+
+	urls := map[string]string{}
+	for _, target := range targets {
+		urls[target.Label] = target.URL
+	}
+
+	paths, err := readSpool(conf.SpoolDir)
+	if err != nil {
+		return fmt.Errorf("failed to read spool directory %s: %s", conf.SpoolDir, err)
+	}
+
+	policy := newRetryPolicy(conf)
+	blocked := map[string]bool{}
+	var remaining []string
+
+	for _, path := range paths {
+		name := filepath.Base(path)
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Warnf("%s: failed to read: %s", name, err)
+			remaining = append(remaining, name)
+			continue
+		}
+
+		var entry SpoolEntry
+		if err := json.Unmarshal(b, &entry); err != nil {
+			log.Warnf("%s: failed to parse: %s", name, err)
+			remaining = append(remaining, name)
+			continue
+		}
+
+		thread := entry.Target + "\n" + entry.ThreadKey
+		if entry.ThreadKey != "" && blocked[thread] {
+			log.Warnf("%s: skipped, an earlier message of thread %s could not be sent", name, entry.ThreadKey)
+			remaining = append(remaining, name)
+			continue
+		}
+
+		url, ok := urls[entry.Target]
+		if !ok {
+			log.Warnf("%s: skipped, no webhook with label %s", name, entry.Target)
+			remaining = append(remaining, name)
+			blocked[thread] = true
+			continue
+		}
+
+		client := newWebhookClient(url, entry.ReplyOption)
+		err = withRetry(entry.Target, policy, func() error {
+			resp, err := client.SendPayload(context.Background(), entry.Payload, entry.ThreadKey)
+			if resp != nil {
+				warnResponse(name, resp.Warnings)
+			}
+			return err
+		})
+		if err != nil {
+			log.Warnf("%s: failed to resend: %s", name, err)
+			remaining = append(remaining, name)
+			blocked[thread] = true
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			log.Warnf("%s: delivered, but failed to remove it from the spool: %s", name, err)
+		}
+	}
+
+	log.Printf("Resent %d of %d spooled messages", len(paths)-len(remaining), len(paths))
+
+	if len(remaining) > 0 {
+		return fmt.Errorf("%d spooled messages could not be resent: %s", len(remaining), strings.Join(remaining, ", "))
+	}
+
+	return nil
+}
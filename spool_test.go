@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+	"github.com/bitrise-io/go-steputils/stepconf"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_spool_and_resend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	status := http.StatusServiceUnavailable
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		received = append(received, r.URL.Query().Get("threadKey")+" "+string(b))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	conf := Config{
+		WebhookURL:       stepconf.Secret("builds|" + server.URL + "?token=secret"),
+		RetryMaxAttempts: 1,
+		SpoolDir:         dir,
+	}
+
+	if _, err := postMessage(conf, googlechat.Message{Text: "first", Thread: &googlechat.Thread{ThreadKey: "main"}}); err == nil {
+		t.Fatalf("Expected an error")
+	}
+	if _, err := postMessage(conf, googlechat.Message{Text: "second", Thread: &googlechat.Thread{ThreadKey: "main"}}); err == nil {
+		t.Fatalf("Expected an error")
+	}
+
+	paths, err := readSpool(dir)
+	if err != nil || len(paths) != 2 {
+		t.Fatalf("Expected 2 spooled messages, got %d: %s", len(paths), err)
+	}
+
+	b, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("Failed to read spooled message: %s", err)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Errorf("Spooled message contains the webhook secret: %s", b)
+	}
+
+	var entry SpoolEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		t.Fatalf("Failed to parse spooled message: %s", err)
+	}
+	if entry.Target != "builds" || entry.ThreadKey != "main" || string(entry.Payload) != `{"text":"first","thread":{"threadKey":"main"}}` {
+		t.Errorf("Spooled message is not correct: %+v", entry)
+	}
+
+	// The first message of the thread still fails, so the second one is kept to preserve the order
+	received = nil
+	if err := resendSpool(conf); err == nil {
+		t.Errorf("Expected an error")
+	}
+	if len(received) != 1 {
+		t.Errorf("Expected only the first message of the thread to be resent, got %v", received)
+	}
+	if paths, _ := readSpool(dir); len(paths) != 2 {
+		t.Errorf("Expected 2 spooled messages, got %d", len(paths))
+	}
+
+	status = http.StatusOK
+	received = nil
+	if err := resendSpool(conf); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	expected := []string{
+		`main {"text":"first","thread":{"threadKey":"main"}}`,
+		`main {"text":"second","thread":{"threadKey":"main"}}`,
+	}
+	if !cmp.Equal(received, expected) {
+		t.Errorf("Resent messages are not correct:\nexpected: %v\ngot:      %v", expected, received)
+	}
+
+	if paths, _ := readSpool(dir); len(paths) != 0 {
+		t.Errorf("Expected the spool to be empty, got %v", paths)
+	}
+}
+
+func Test_spool_unreachable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	conf := Config{
+		WebhookURL:       stepconf.Secret("builds|" + server.URL + "/v1/spaces/AAA/messages?key=SECRETKEY&token=SECRETTOKEN"),
+		RetryMaxAttempts: 1,
+		SpoolDir:         dir,
+	}
+
+	if _, err := postMessage(conf, googlechat.Message{Text: "text"}); err == nil {
+		t.Fatalf("Expected an error")
+	}
+
+	paths, err := readSpool(dir)
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("Expected 1 spooled message, got %d: %s", len(paths), err)
+	}
+
+	b, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("Failed to read spooled message: %s", err)
+	}
+	if strings.Contains(string(b), "SECRET") || strings.Contains(string(b), "key=") {
+		t.Errorf("Spooled message contains the webhook query: %s", b)
+	}
+	if !strings.Contains(string(b), `"error":"giving up after 1 attempts: failed to send the request: `) {
+		t.Errorf("Spooled message doesn't contain the error: %s", b)
+	}
+}
+
+func Test_spoolFailures_permanent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	results := []TargetResult{
+		{Target: Target{Label: "rejected"}, Err: googlechat.NewDeliveryError(os.ErrInvalid, false)},
+		{Target: Target{Label: "delivered"}},
+	}
+
+	spoolFailures(Config{SpoolDir: dir}, googlechat.Message{}, []byte("{}"), results)
+
+	if paths, _ := filepath.Glob(filepath.Join(dir, "*")); len(paths) != 0 {
+		t.Errorf("Expected no spooled messages, got %v", paths)
+	}
+}
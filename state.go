@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// stateFileName is the name of the state file in the Bitrise cache directory
+const stateFileName = "google-chat-state.json"
+
+// PreviousBuildLookup looks up the status of the previous build of the same workflow and branch
+type PreviousBuildLookup interface {
+	// PreviousStatus returns the status of the previous build, empty if there is none
+	PreviousStatus(build BuildContext) (BuildStatus, error)
+}
+
+// previousStatusInput is the status of the previous build passed using the previous_build_status input
+type previousStatusInput string
+
+// PreviousStatus implements PreviousBuildLookup.PreviousStatus
+func (s previousStatusInput) PreviousStatus(build BuildContext) (BuildStatus, error) {
+	return parseBuildStatus(string(s)), nil
+}
+
+// BuildRecord is the status of the last build of a workflow and branch saved in the state file
+type BuildRecord struct {
+	Status      BuildStatus `json:"status"`
+	BuildNumber string      `json:"buildNumber,omitempty"`
+	RecordedAt  time.Time   `json:"recordedAt"`
+}
+
+// stateFile saves the status of the last build of every workflow and branch in a JSON file.
+// The file has to be cached between builds, e.g. using the Cache steps.
+type stateFile struct {
+	path string
+}
+
+// stateKey returns the key of the workflow and branch of the build in the state file
+func stateKey(build BuildContext) string {
+	return build.Workflow + "@" + firstNonEmpty(build.Branch, build.Tag)
+}
+
+func (f stateFile) read() (map[string]BuildRecord, error) {
+	records := map[string]BuildRecord{}
+
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %s", f.path, err)
+	}
+
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %s", f.path, err)
+	}
+
+	return records, nil
+}
+
+// PreviousStatus implements PreviousBuildLookup.PreviousStatus
+func (f stateFile) PreviousStatus(build BuildContext) (BuildStatus, error) {
+	records, err := f.read()
+	if err != nil {
+		return "", err
+	}
+
+	return records[stateKey(build)].Status, nil
+}
+
+// Record saves the status of the build. Aborted builds are not saved, so the next build is compared to the last completed one.
+func (f stateFile) Record(build BuildContext) error {
+	if build.Status == statusAborted {
+		return nil
+	}
+
+	records, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	records[stateKey(build)] = BuildRecord{
+		Status:      build.Status,
+		BuildNumber: build.BuildNumber,
+		RecordedAt:  now(),
+	}
+
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory of state file %s: %s", f.path, err)
+	}
+	if err := ioutil.WriteFile(f.path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write state file %s: %s", f.path, err)
+	}
+
+	return nil
+}
+
+// newStateFile returns the state file of the state_file input, falling back to the Bitrise cache directory. It returns nil if neither is set.
+func newStateFile(conf Config) *stateFile {
+	switch {
+	case conf.StateFile != "":
+		return &stateFile{path: conf.StateFile}
+	case conf.CacheDir != "":
+		return &stateFile{path: filepath.Join(conf.CacheDir, stateFileName)}
+	default:
+		return nil
+	}
+}
+
+// newPreviousBuildLookup returns the lookup of the previous build: the previous_build_status input if set, otherwise the state file.
+// It returns nil if the previous build can't be looked up.
+func newPreviousBuildLookup(conf Config) PreviousBuildLookup {
+	if conf.PreviousBuildStatus != "" {
+		return previousStatusInput(conf.PreviousBuildStatus)
+	}
+	if file := newStateFile(conf); file != nil {
+		return file
+	}
+	return nil
+}
+
+// resolveBuildState sets the previous status and the state of the build. If the previous build can't be looked up,
+// the state is success or failure.
+func resolveBuildState(build *BuildContext, lookup PreviousBuildLookup) {
+	if lookup != nil {
+		previous, err := lookup.PreviousStatus(*build)
+		if err != nil {
+			log.Warnf("Warning: %s", err)
+		}
+		build.PreviousStatus = previous
+	}
+
+	build.State = resolveState(build.Status, build.PreviousStatus)
+}
+
+// recordBuildState saves the status of the build in the state file, so the next build can be compared to it
+func recordBuildState(conf Config, build BuildContext) {
+	file := newStateFile(conf)
+	if file == nil {
+		return
+	}
+
+	if err := file.Record(build); err != nil {
+		log.Warnf("Warning: %s", err)
+	}
+}
+
+// parseNotifyOn parses the comma separated states of the notify_on input. All states are notified if it is empty.
+func parseNotifyOn(notifyOn string) (map[BuildState]bool, error) {
+	states := map[BuildState]bool{}
+	if strings.TrimSpace(notifyOn) == "" {
+		for _, state := range buildStates {
+			states[state] = true
+		}
+		return states, nil
+	}
+
+	valid := map[BuildState]bool{}
+	for _, state := range buildStates {
+		valid[state] = true
+	}
+
+	for _, value := range strings.Split(notifyOn, ",") {
+		state := BuildState(strings.TrimSpace(value))
+		if !valid[state] {
+			return nil, fmt.Errorf("Unknown state %q in notify_on, expected one of %s", state, joinStates(buildStates))
+		}
+		states[state] = true
+	}
+
+	return states, nil
+}
+
+func joinStates(states []BuildState) string {
+	values := make([]string, len(states))
+	for i, state := range states {
+		values[i] = string(state)
+	}
+	return strings.Join(values, ", ")
+}
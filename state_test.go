@@ -0,0 +1,215 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_resolveState(t *testing.T) {
+	tests := []struct {
+		status   BuildStatus
+		previous BuildStatus
+		output   BuildState
+	}{
+		{status: statusSuccess, previous: "", output: stateSuccess},
+		{status: statusSuccess, previous: statusSuccess, output: stateSuccess},
+		{status: statusSuccess, previous: statusFailed, output: stateFixed},
+		{status: statusFailed, previous: "", output: stateFailure},
+		{status: statusFailed, previous: statusSuccess, output: stateFailure},
+		{status: statusFailed, previous: statusFailed, output: stateStillFailing},
+		{status: statusAborted, previous: statusFailed, output: stateAborted},
+	}
+
+	for _, tc := range tests {
+		if state := resolveState(tc.status, tc.previous); state != tc.output {
+			t.Errorf("State of %s after %q is not correct: expected %s, got %s", tc.status, tc.previous, tc.output, state)
+		}
+	}
+}
+
+func Test_stateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fixedNow := time.Date(2020, 6, 23, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixedNow }
+	defer func() { now = time.Now }()
+
+	file := stateFile{path: filepath.Join(dir, "cache", stateFileName)}
+	mainBuild := BuildContext{Workflow: "primary", Branch: "main", BuildNumber: "41"}
+	feature := BuildContext{Workflow: "primary", Branch: "feature"}
+
+	steps := []struct {
+		name     string
+		build    BuildContext
+		status   BuildStatus
+		previous BuildStatus
+	}{
+		{name: "No previous build", build: mainBuild, status: statusFailed, previous: ""},
+		{name: "Previous build failed", build: mainBuild, status: statusAborted, previous: statusFailed},
+		{name: "Aborted build is not recorded", build: mainBuild, status: statusSuccess, previous: statusFailed},
+		{name: "Previous build succeeded", build: mainBuild, status: statusSuccess, previous: statusSuccess},
+		{name: "Other branch", build: feature, status: statusSuccess, previous: ""},
+	}
+
+	for _, step := range steps {
+		previous, err := file.PreviousStatus(step.build)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", step.name, err)
+		}
+		if previous != step.previous {
+			t.Errorf("%s: previous status is not correct: expected %q, got %q", step.name, step.previous, previous)
+		}
+
+		step.build.Status = step.status
+		if err := file.Record(step.build); err != nil {
+			t.Fatalf("%s: failed to record the build: %s", step.name, err)
+		}
+	}
+
+	records, err := file.read()
+	if err != nil {
+		t.Fatalf("Failed to read the state file: %s", err)
+	}
+
+	expected := map[string]BuildRecord{
+		"primary@main":    {Status: statusSuccess, BuildNumber: "41", RecordedAt: fixedNow},
+		"primary@feature": {Status: statusSuccess, RecordedAt: fixedNow},
+	}
+	if !cmp.Equal(records, expected) {
+		t.Errorf("State file is not correct: %s", cmp.Diff(expected, records))
+	}
+}
+
+func Test_stateFile_invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, stateFileName)
+	if err := ioutil.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatalf("Failed to write the state file: %s", err)
+	}
+
+	build := BuildContext{Status: statusSuccess}
+	resolveBuildState(&build, stateFile{path: path})
+
+	if build.PreviousStatus != "" || build.State != stateSuccess {
+		t.Errorf("Invalid state file is not ignored: %+v", build)
+	}
+}
+
+func Test_newPreviousBuildLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		output PreviousBuildLookup
+	}{
+		{name: "None", config: Config{}, output: nil},
+		{name: "Cache directory", config: Config{CacheDir: "/cache"}, output: &stateFile{path: "/cache/google-chat-state.json"}},
+		{name: "State file", config: Config{CacheDir: "/cache", StateFile: "/tmp/state.json"}, output: &stateFile{path: "/tmp/state.json"}},
+		{name: "Previous status input", config: Config{StateFile: "/tmp/state.json", PreviousBuildStatus: "failed"}, output: previousStatusInput("failed")},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			lookup := newPreviousBuildLookup(tc.config)
+
+			if !cmp.Equal(lookup, tc.output, cmp.AllowUnexported(stateFile{})) {
+				t.Errorf("Returned lookup is not correct: expected %#v, got %#v", tc.output, lookup)
+			}
+		})
+	}
+}
+
+func Test_parseNotifyOn(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output map[BuildState]bool
+		err    string
+	}{
+		{
+			name:   "All states by default",
+			input:  "",
+			output: map[BuildState]bool{stateSuccess: true, stateFailure: true, stateFixed: true, stateStillFailing: true, stateAborted: true},
+		},
+		{
+			name:   "Recoveries and failures",
+			input:  "fixed, failure,still_failing",
+			output: map[BuildState]bool{stateFailure: true, stateFixed: true, stateStillFailing: true},
+		},
+		{
+			name:  "Unknown state",
+			input: "fixed,recovered",
+			err:   `Unknown state "recovered" in notify_on, expected one of success, failure, fixed, still_failing, aborted`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			states, err := parseNotifyOn(tc.input)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if !cmp.Equal(states, tc.output) {
+				t.Errorf("Returned states are not correct: %s", cmp.Diff(tc.output, states))
+			}
+		})
+	}
+}
+
+func Test_newMessage_states(t *testing.T) {
+	config := Config{
+		Message:               "passed",
+		MessageOnError:        "failed",
+		MessageOnFixed:        "fixed",
+		MessageOnStillFailing: "still failing",
+		Title:                 "Build",
+		TitleOnAborted:        "Build aborted",
+		Text:                  "text",
+	}
+
+	tests := []struct {
+		state   BuildState
+		message string
+		title   string
+	}{
+		{state: stateSuccess, message: "passed", title: "Build"},
+		{state: stateFailure, message: "failed", title: "Build"},
+		{state: stateFixed, message: "fixed", title: "Build"},
+		{state: stateStillFailing, message: "still failing", title: "Build"},
+		{state: stateAborted, message: "failed", title: "Build aborted"},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.state), func(t *testing.T) {
+			build := BuildContext{Status: statusFailed, State: tc.state}
+			if tc.state == stateSuccess || tc.state == stateFixed {
+				build.Status = statusSuccess
+			}
+
+			msg, err := newMessage(config, build)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if msg.Text != tc.message || msg.Cards[0].Header.Title != tc.title {
+				t.Errorf("Message is not correct: expected %q with title %q, got %q with title %q", tc.message, tc.title, msg.Text, msg.Cards[0].Header.Title)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// templateInputs are the inputs which are rendered as a template with the BuildContext
+var templateInputs = []string{"message", "title", "subtitle", "text", "key_value", "buttons"}
+
+// environ returns the environment variables. Replaced in tests.
+var environ = os.Environ
+
+// templateFuncs are the functions available in the templates. Text functions also accept fields like .Status.
+var templateFuncs = template.FuncMap{
+	"truncate":         truncate,
+	"default":          defaultValue,
+	"join":             join,
+	"upper":            stringFunc(strings.ToUpper),
+	"lower":            stringFunc(strings.ToLower),
+	"shortSHA":         shortSHA,
+	"humanizeDuration": humanizeDuration,
+	"humanize":         humanizeDuration,
+	"escapeHTML":       stringFunc(html.EscapeString),
+	"urlquery":         stringFunc(url.QueryEscape),
+	"json":             toJSON,
+	"raw":              raw,
+}
+
+// stringFunc adapts a string function to accept any value, formatted using fmt.Sprint
+func stringFunc(f func(string) string) func(interface{}) string {
+	return func(v interface{}) string {
+		return f(fmt.Sprint(v))
+	}
+}
+
+// CommitShort returns the abbreviated commit hash of the build
+func (b BuildContext) CommitShort() string {
+	return shortSHA(b.Commit)
+}
+
+// Failed returns true if the build did not succeed, including aborted builds
+func (b BuildContext) Failed() bool {
+	return !b.Succeeded()
+}
+
+// Aborted returns true if the build was aborted
+func (b BuildContext) Aborted() bool {
+	return b.Status == statusAborted
+}
+
+// Duration returns the time since the build was triggered, zero if unknown
+func (b BuildContext) Duration() time.Duration {
+	if b.TriggeredAt.IsZero() || b.Now.Before(b.TriggeredAt) {
+		return 0
+	}
+	return b.Now.Sub(b.TriggeredAt)
+}
+
+// truncate shortens s to at most length characters, ending with … if it was shortened. Use as {{ .CommitMessage | truncate 50 }}
+func truncate(length int, v interface{}) string {
+	s := fmt.Sprint(v)
+	runes := []rune(s)
+	if length <= 0 || len(runes) <= length {
+		return s
+	}
+	if length == 1 {
+		return "…"
+	}
+	return string(runes[:length-1]) + "…"
+}
+
+// defaultValue returns value, or def if value is empty. Use as {{ .Tag | default "untagged" }}
+func defaultValue(def string, v interface{}) string {
+	if value := fmt.Sprint(v); value != "" {
+		return value
+	}
+	return def
+}
+
+// join joins the non-empty values with the separator, e.g. {{ join " · " .Branch .Tag }}
+func join(sep string, values ...interface{}) string {
+	var nonEmpty []string
+	for _, v := range values {
+		if value := fmt.Sprint(v); value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
+
+// raw marks a value as markup, which is not escaped when escape_values is enabled, e.g. {{ .BuildURL | raw }}
+func raw(v interface{}) string {
+	return fmt.Sprint(v)
+}
+
+// toJSON encodes a value as JSON, e.g. "text": {{ .CommitMessage | json }} in a template file
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// shortSHA returns the first 7 characters of a commit hash
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// humanizeDuration formats a duration rounded to seconds, e.g. 1h 2m 3s
+func humanizeDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < time.Second {
+		return "0s"
+	}
+
+	hours := d / time.Hour
+	minutes := d % time.Hour / time.Minute
+	seconds := d % time.Minute / time.Second
+
+	var parts []string
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
+	}
+	if seconds > 0 {
+		parts = append(parts, fmt.Sprintf("%ds", seconds))
+	}
+	return strings.Join(parts, " ")
+}
+
+// escapeActions html escapes the output of the actions of the template, while the text of the template is kept as markup.
+// Actions ending with raw, escapeHTML or urlquery are not escaped, values encoded by json are escaped before encoding
+// unless they are marked as raw.
+func escapeActions(node parse.Node) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, n := range node.Nodes {
+			escapeActions(n)
+		}
+	case *parse.IfNode:
+		escapeActions(node.List)
+		escapeActions(node.ElseList)
+	case *parse.RangeNode:
+		escapeActions(node.List)
+		escapeActions(node.ElseList)
+	case *parse.WithNode:
+		escapeActions(node.List)
+		escapeActions(node.ElseList)
+	case *parse.ActionNode:
+		// Declarations like {{ $branch := .Branch }} don't output anything
+		if len(node.Pipe.Decl) > 0 {
+			return
+		}
+
+		cmds := node.Pipe.Cmds
+		last := cmds[len(cmds)-1]
+		escape := &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      last.Pos,
+			Args:     []parse.Node{parse.NewIdentifier("escapeHTML").SetPos(last.Pos)},
+		}
+
+		switch {
+		case isUnescapedCommand(last):
+		case commandName(last) == "json" && len(cmds) > 1 && isUnescapedCommand(cmds[len(cmds)-2]):
+			// A value marked as raw before encoding, e.g. {{ .BuildURL | raw | json }}, is not escaped
+		case commandName(last) == "json":
+			node.Pipe.Cmds = append(cmds[:len(cmds)-1:len(cmds)-1], escape, last)
+		default:
+			node.Pipe.Cmds = append(cmds, escape)
+		}
+	}
+}
+
+// isUnescapedCommand returns true if the output of the command is not escaped: raw, escapeHTML or urlquery
+func isUnescapedCommand(cmd *parse.CommandNode) bool {
+	switch commandName(cmd) {
+	case "raw", "escapeHTML", "urlquery":
+		return true
+	}
+	return false
+}
+
+// commandName returns the name of the function a command of a pipeline calls, e.g. truncate for truncate 50
+func commandName(cmd *parse.CommandNode) string {
+	if identifier, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		return identifier.Ident
+	}
+	return ""
+}
+
+// renderTemplate executes the value of an input as a template with the BuildContext. Values without an action are returned as is.
+// If escape is set the output of the actions is html escaped, see escapeActions.
+// Errors name the input and the position in the template, e.g. template: title:1:3.
+func renderTemplate(input, value string, build BuildContext, escape bool) (string, error) {
+	value = literalSubstitutedValues(value)
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+
+	tmpl, err := template.New(input).Funcs(templateFuncs).Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %s", input, err)
+	}
+	if escape {
+		escapeActions(tmpl.Tree.Root)
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, build); err != nil {
+		return "", fmt.Errorf("failed to render %s: %s", input, err)
+	}
+
+	return b.String(), nil
+}
+
+// literalSubstitutedValues escapes the template syntax brought into value by environment variables, e.g. a commit message
+// with {{ which Bitrise substituted into an input, so these values are rendered as written instead of as templates.
+// The inputs themselves are not taken into account. The values are also matched HTML escaped, see escapeSubstitutedValues.
+func literalSubstitutedValues(value string) string {
+	inputs := map[string]bool{}
+	for _, input := range configInputs() {
+		inputs[input.Name] = true
+	}
+
+	literals := map[string]bool{}
+	for _, env := range environ() {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) != 2 || inputs[parts[0]] || strings.HasPrefix(parts[0], envPrefix) || !strings.Contains(parts[1], "{{") {
+			continue
+		}
+
+		for _, literal := range []string{parts[1], html.EscapeString(parts[1])} {
+			if strings.Contains(value, literal) {
+				literals[literal] = true
+			}
+		}
+	}
+	if len(literals) == 0 {
+		return value
+	}
+
+	// Longer values are replaced first, so a value containing another one is escaped as a whole
+	var sorted []string
+	for literal := range literals {
+		sorted = append(sorted, literal)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	var replacements []string
+	for _, literal := range sorted {
+		replacements = append(replacements, literal, strings.Replace(literal, "{{", `{{"{{"}}`, -1))
+	}
+	return strings.NewReplacer(replacements...).Replace(value)
+}
+
+// renderInputs renders the template inputs of the config with the BuildContext. If escape_values is enabled
+// the values are escaped in the inputs shown as card text.
+func renderInputs(c Config, build BuildContext) (Config, error) {
+	v := reflect.ValueOf(&c).Elem()
+	inputs := overridableInputs()
+
+	for _, name := range templateInputs {
+		field := v.Field(inputs[name].Field)
+
+		escape := c.EscapeValues && containsString(escapedInputs, name)
+		rendered, err := renderTemplate(name, field.String(), build, escape)
+		if err != nil {
+			return c, err
+		}
+		field.SetString(rendered)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_renderTemplate(t *testing.T) {
+	triggeredAt := time.Date(2020, 6, 23, 12, 0, 0, 0, time.UTC)
+	build := BuildContext{
+		Status:        statusFailed,
+		Branch:        "feature/chat",
+		Commit:        "0123456789abcdef",
+		CommitMessage: "Add <b>templates</b> to the inputs",
+		BuildURL:      "https://app.bitrise.io/build/1",
+		TriggeredAt:   triggeredAt,
+		Now:           triggeredAt.Add(time.Hour + 2*time.Minute + 3*time.Second),
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		escape bool
+		output string
+		err    string
+	}{
+		{name: "Literal", input: "Build of {main}", output: "Build of {main}"},
+		{name: "Fields", input: "{{ .Branch }} at {{ .CommitShort }}", output: "feature/chat at 0123456"},
+		{name: "Condition", input: "{{ if .Failed }}Failed{{ else }}Passed{{ end }}", output: "Failed"},
+		{name: "Duration", input: "{{ .Duration | humanize }}", output: "1h 2m 3s"},
+		{name: "Truncate", input: "{{ .CommitMessage | truncate 10 }}", output: "Add <b>te…"},
+		{name: "Default", input: `{{ .Tag | default "untagged" }}`, output: "untagged"},
+		{name: "Join", input: `{{ join " · " .Branch .Tag .PullRequest }}`, output: "feature/chat"},
+		{name: "Upper", input: "{{ .Status | upper }}", output: "FAILED"},
+		{name: "Short SHA", input: "{{ shortSHA .Commit }}", output: "0123456"},
+		{name: "Escape HTML", input: "{{ .CommitMessage | escapeHTML }}", output: "Add &lt;b&gt;templates&lt;/b&gt; to the inputs"},
+		{name: "URL query", input: "https://example.org/?branch={{ urlquery .Branch }}", output: "https://example.org/?branch=feature%2Fchat"},
+		{name: "Escaped value", input: "<b>{{ .CommitMessage | truncate 10 }}</b>", escape: true, output: "<b>Add &lt;b&gt;te…</b>"},
+		{name: "Escaped condition", input: "{{ if .Failed }}<i>{{ .CommitMessage }}</i>{{ end }}", escape: true, output: "<i>Add &lt;b&gt;templates&lt;/b&gt; to the inputs</i>"},
+		{name: "Escaped json", input: `{"content":{{ .CommitMessage | json }}}`, escape: true, output: `{"content":"Add \u0026lt;b\u0026gt;templates\u0026lt;/b\u0026gt; to the inputs"}`},
+		{name: "Not escaped twice", input: "{{ $message := .CommitMessage }}{{ escapeHTML $message }}", escape: true, output: "Add &lt;b&gt;templates&lt;/b&gt; to the inputs"},
+		{name: "Raw value", input: `<a href="{{ .BuildURL | raw }}">{{ .Branch | raw }}</a>`, escape: true, output: `<a href="https://app.bitrise.io/build/1">feature/chat</a>`},
+		{name: "Parse error", input: "{{ .Branch ", err: "failed to parse title: template: title:1: unclosed action"},
+		{name: "Unknown function", input: "{{ .Branch | lowercase }}", err: `failed to parse title: template: title:1: function "lowercase" not defined`},
+		{name: "Unknown field", input: "Build\n{{ .Unknown }}", err: `failed to render title: template: title:2:3: executing "title" at <.Unknown>: can't evaluate field Unknown in type main.BuildContext`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rendered, err := renderTemplate("title", tc.input, build, tc.escape)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if rendered != tc.output {
+				t.Errorf("Rendered template is not correct: expected %q, got %q", tc.output, rendered)
+			}
+		})
+	}
+}
+
+func Test_humanizeDuration(t *testing.T) {
+	tests := []struct {
+		input  time.Duration
+		output string
+	}{
+		{input: 0, output: "0s"},
+		{input: 1400 * time.Millisecond, output: "1s"},
+		{input: 90 * time.Second, output: "1m 30s"},
+		{input: 2 * time.Hour, output: "2h"},
+	}
+
+	for _, tc := range tests {
+		if humanized := humanizeDuration(tc.input); humanized != tc.output {
+			t.Errorf("Duration %s is not humanized correctly: expected %s, got %s", tc.input, tc.output, humanized)
+		}
+	}
+}
+
+func Test_renderInputs(t *testing.T) {
+	config := Config{
+		Message:  "{{ .Branch }} failed",
+		Title:    "Build #{{ .BuildNumber }}",
+		Subtitle: "{{ .CommitShort }}",
+		Text:     "Took {{ .Duration | humanize }}",
+		KeyValue: `[{"topLabel":"Branch","content":"{{ .Branch }}"}]`,
+		Buttons:  "text|Open|{{ .BuildURL }}",
+		Images:   "{{ .Branch }}",
+	}
+	build := BuildContext{Branch: "main", BuildNumber: "42", Commit: "abcdef0123", BuildURL: "https://example.org/42"}
+
+	rendered, err := renderInputs(config, build)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := Config{
+		Message:  "main failed",
+		Title:    "Build #42",
+		Subtitle: "abcdef0",
+		Text:     "Took 0s",
+		KeyValue: `[{"topLabel":"Branch","content":"main"}]`,
+		Buttons:  "text|Open|https://example.org/42",
+		Images:   "{{ .Branch }}",
+	}
+	if rendered != expected {
+		t.Errorf("Rendered inputs are not correct:\nexpected: %+v\ngot:      %+v", expected, rendered)
+	}
+}
+
+func Test_renderInputs_substitutedTemplate(t *testing.T) {
+	environ = func() []string {
+		return []string{
+			"GIT_CLONE_COMMIT_MESSAGE_SUBJECT=Fix {{ in docs",
+			"GIT_CLONE_COMMIT_MESSAGE_BODY=Update {{ .Branch }} <docs>",
+			"BITRISE_GIT_MESSAGE=Quote {{ .CommitMessage }}",
+			"text=Build: Fix {{ in docs",
+		}
+	}
+	defer func() { environ = os.Environ }()
+
+	tests := []struct {
+		name   string
+		config Config
+		output Config
+		err    string
+	}{
+		{
+			name:   "Substituted text with unbalanced braces",
+			config: Config{Title: "{{ .Branch }}", Text: "Build: Fix {{ in docs"},
+			output: Config{Title: "main", Text: "Build: Fix {{ in docs"},
+		},
+		{
+			name:   "Substituted text with a template",
+			config: Config{Title: "{{ .Branch }}: Update {{ .Branch }} <docs>"},
+			output: Config{Title: "main: Update {{ .Branch }} <docs>"},
+		},
+		{
+			name:   "Escaped substituted text with a template",
+			config: Config{Text: "<b>{{ .Branch }}</b> Update {{ .Branch }} &lt;docs&gt;", EscapeValues: true},
+			output: Config{Text: "<b>main</b> Update {{ .Branch }} &lt;docs&gt;", EscapeValues: true},
+		},
+		{
+			name:   "Substituted text with a template in JSON",
+			config: Config{KeyValue: `[{"topLabel": {{ .Branch | json }}, "content": "Quote {{ .CommitMessage }}"}]`},
+			output: Config{KeyValue: `[{"topLabel": "main", "content": "Quote {{ .CommitMessage }}"}]`},
+		},
+		{
+			name:   "Invalid template of the input",
+			config: Config{Text: "Build: {{ .Branch "},
+			err:    "failed to parse text: template: text:1: unclosed action",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rendered, err := renderInputs(tc.config, BuildContext{Branch: "main", CommitMessage: `Say "hi"`})
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if tc.err == "" && rendered != tc.output {
+				t.Errorf("Rendered inputs are not correct:\nexpected: %+v\ngot:      %+v", tc.output, rendered)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/Corneel-D/bitrise-step-google-chat/googlechat"
+)
+
+// renderThreadKey executes the thread key template, e.g. `pr-{{ .PullRequest }}`, with the fields of the BuildContext
+func renderThreadKey(key string, build BuildContext) (string, error) {
+	rendered, err := renderTemplate("thread_key", key, build, false)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(rendered), nil
+}
+
+// Values of the thread_reply_option input and the messageReplyOption query parameter they map to
+var replyOptions = map[string]string{
+	"fallback_to_new_thread": googlechat.ReplyMessageFallbackToNewThread,
+	"reply_or_fail":          googlechat.ReplyMessageOrFail,
+}
+
+// replyOptionValue returns the messageReplyOption for a thread_reply_option, falling back to a new thread by default
+func replyOptionValue(replyOption string) string {
+	if option, ok := replyOptions[replyOption]; ok {
+		return option
+	}
+	return replyOptions["fallback_to_new_thread"]
+}
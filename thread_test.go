@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func Test_renderThreadKey(t *testing.T) {
+	build := BuildContext{
+		Branch:      "feature/threads",
+		PullRequest: "42",
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		err    string
+	}{
+		{name: "Empty", input: "", output: ""},
+		{name: "Literal", input: " builds ", output: "builds"},
+		{name: "Pull request", input: "pr-{{ .PullRequest }}", output: "pr-42"},
+		{name: "Branch", input: "{{ .Branch }}", output: "feature/threads"},
+		{name: "Invalid template", input: "pr-{{ .PullRequest ", err: "failed to parse thread_key: template: thread_key:1: unclosed action"},
+		{name: "Unknown field", input: "{{ .Unknown }}", err: "failed to render thread_key: template: thread_key:1:3: executing \"thread_key\" at <.Unknown>: can't evaluate field Unknown in type main.BuildContext"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := renderThreadKey(tc.input, build)
+			if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+
+			if key != tc.output {
+				t.Errorf("Returned thread key is not correct: expected %s, got %s", tc.output, key)
+			}
+		})
+	}
+}